@@ -2,4 +2,14 @@ module github.com/zostay/gordy
 
 go 1.19
 
-require github.com/zostay/go-std v0.0.2
+require (
+	github.com/stretchr/testify v1.8.2
+	github.com/zostay/go-std v0.0.2
+	golang.org/x/text v0.14.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
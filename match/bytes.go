@@ -1,6 +1,10 @@
 package match
 
 import (
+	"errors"
+	"fmt"
+	"io"
+
 	"github.com/zostay/go-std/slices"
 
 	"github.com/zostay/gordy/parser"
@@ -97,6 +101,8 @@ func OneByte(
 ) parser.Matcher {
 	return &Bytes{
 		t:    t,
+		from: 1,
+		to:   1,
 		pred: AnyBytes(preds...),
 	}
 }
@@ -118,26 +124,89 @@ func NBytes(
 	}
 }
 
-// Match returns a Match with the configured token.Tag if the next byte in the
-// input matches the predicate. It returns nil otherwise.
-func (b *Bytes) Match(p *parser.Input) (*parser.Match, error) {
-	bs := make([]byte, b.from, b.from+b.to)
-	for i := 0; i <= b.from; i++ {
-		c, ok, err := b.matchOne(p)
+// CharClass returns a Matcher that matches exactly one byte in the character
+// class described by spec, which is written like the inside of a regular
+// expression bracket expression, such as "a-zA-Z0-9_". A leading "^" negates
+// the class, so "^0-9" matches any byte that is not a digit. A backslash
+// escapes the byte that follows it, so "\\-" and "\\^" match a literal "-"
+// and "^". A "-" at the start or end of the class is also taken literally.
+// Each byte of spec is taken as a byte, so use OneRune for classes of
+// multi-byte runes.
+//
+// An error wrapping ErrCharClass is returned if the class is empty, ends with a
+// lone backslash, or has a range whose end is before its start.
+func CharClass(t token.Tag, spec string) (parser.Matcher, error) {
+	var set [256]bool
+
+	cs := []byte(spec)
+	negate := len(cs) > 0 && cs[0] == '^'
+	if negate {
+		cs = cs[1:]
+	}
+
+	if len(cs) == 0 {
+		return nil, fmt.Errorf("%w: %q is empty", ErrCharClass, spec)
+	}
+
+	// next returns the next byte of the class, unescaping it if needed
+	next := func() (byte, error) {
+		c := cs[0]
+		cs = cs[1:]
+		if c != '\\' {
+			return c, nil
+		}
+
+		if len(cs) == 0 {
+			return 0, fmt.Errorf("%w: %q ends with a backslash",
+				ErrCharClass, spec)
+		}
+
+		c = cs[0]
+		cs = cs[1:]
+		return c, nil
+	}
+
+	for len(cs) > 0 {
+		lo, err := next()
 		if err != nil {
-			p.Trace(parser.StageFail, "Bytes.Match", b.t, b.from, b.to, b.pred, i, err)
 			return nil, err
 		}
 
-		p.Trace(parser.StageTry, "Bytes.Match", b.t, b.from, b.to, b.pred, i)
-		if !ok {
-			return nil, nil
+		if len(cs) < 2 || cs[0] != '-' {
+			set[lo] = true
+			continue
 		}
 
-		bs[i] = c
+		cs = cs[1:]
+		hi, err := next()
+		if err != nil {
+			return nil, err
+		}
+
+		if hi < lo {
+			return nil, fmt.Errorf("%w: range %q-%q in %q is out of order",
+				ErrCharClass, lo, hi, spec)
+		}
+
+		for c := int(lo); c <= int(hi); c++ {
+			set[c] = true
+		}
 	}
 
-	for i := b.from + 1; i <= b.to; i++ {
+	return OneByte(t, func(b byte) bool {
+		return set[b] != negate
+	}), nil
+}
+
+// Match returns a Match with the configured token.Tag if the next bytes in the
+// input match the predicate at least from times. At most to bytes will be
+// matched. It returns nil otherwise and the input is restored.
+func (b *Bytes) Match(p *parser.Input) (*parser.Match, error) {
+	p = p.MayFail()
+	start := p.Offset()
+
+	bs := make([]byte, 0, b.to)
+	for i := 0; i < b.to; i++ {
 		c, ok, err := b.matchOne(p)
 		if err != nil {
 			p.Trace(parser.StageFail, "Bytes.Match", b.t, b.from, b.to, b.pred, i, err)
@@ -146,31 +215,49 @@ func (b *Bytes) Match(p *parser.Input) (*parser.Match, error) {
 
 		p.Trace(parser.StageTry, "Bytes.Match", b.t, b.from, b.to, b.pred, i)
 		if !ok {
+			if i < b.from {
+				return nil, nil
+			}
 			break
 		}
 
 		bs = append(bs, c)
 	}
 
-	m := &parser.Match{Tag: b.t, Content: []byte(string(bs))}
+	p.Keep()
+
+	m := &parser.Match{
+		Tag:     b.t,
+		Content: bs,
+		Start:   start,
+		End:     start + len(bs),
+	}
 	p.Trace(parser.StageGot, "Bytes.Match", b.t, b.from, b.to, b.pred, m)
 	return m, nil
 }
 
 // matchOne returns the matched byte and true or zero and false if no byte was
-// matched.
+// matched. The byte is only consumed if it matches. The end of input is
+// treated as a failure to match.
 func (b *Bytes) matchOne(p *parser.Input) (byte, bool, error) {
-	var bs [1]byte
-	_, err := p.Read(bs[:])
-	if err != nil {
+	bs, err := p.PeekRef(1)
+	if len(bs) == 0 {
+		if errors.Is(err, io.EOF) {
+			return 0, false, nil
+		}
 		return 0, false, err
 	}
 
-	if b.pred(bs[0]) {
-		return bs[0], true, nil
+	c := bs[0]
+	if !b.pred(c) {
+		return 0, false, nil
 	}
 
-	return 0, false, nil
+	if _, err := p.ReadByte(); err != nil {
+		return 0, false, err
+	}
+
+	return c, true, nil
 }
 
 func extractPredFromBytes(b *Bytes) BytePredicate {
@@ -181,22 +268,354 @@ func extractPredFromBytes(b *Bytes) BytePredicate {
 // Bytes Matcher with predicates of the given Bytes Matchers such that a match
 // occurs if the next byte in the input matches any of those predicates. The
 // returned Match (when found), will have the token.Tag of this Bytes Matcher.
+// The new Matcher matches the same number of bytes as this one.
 func (b *Bytes) AndAlso(bs ...*Bytes) *Bytes {
 	preds := slices.Map(bs, extractPredFromBytes)
-	slices.Unshift(preds, b.pred)
+	preds = slices.Unshift(preds, b.pred)
 	return &Bytes{
 		t:    b.t,
+		from: b.from,
+		to:   b.to,
 		pred: AnyBytes(preds...),
 	}
 }
 
 // ButNot creates a new Bytes Matcher which combines the predicate of this
 // Bytes Matcher with predicates of the given Bytes Matchers such that a match
-// is successful if it matches this Bytes Matcher, but not those.
+// is successful if it matches this Bytes Matcher, but not those. The new Matcher
+// matches the same number of bytes as this one.
 func (b *Bytes) ButNot(bs ...*Bytes) *Bytes {
 	preds := slices.Map(bs, extractPredFromBytes)
 	return &Bytes{
 		t:    b.t,
+		from: b.from,
+		to:   b.to,
 		pred: ThisButNotThatBytes(b.pred, AnyBytes(preds...)),
 	}
 }
+
+// Scan returns a Matcher that feeds bytes from the input to the given scanner
+// function. The scanner is given each byte along with a pointer to a state
+// value that it may use between calls, which starts at zero for every match.
+// Each byte is consumed while the scanner returns true for consume.
+// Scanning stops when the scanner reports done, when the scanner declines to
+// consume a byte, which is left in the input, or at the end of input. If no
+// bytes were consumed, it returns nil.
+//
+// This allows for tokens that are easier to recognize with a hand-written
+// scanner to be part of a grammar built from matchers.
+func Scan(
+	t token.Tag,
+	fn func(b byte, state *int) (consume bool, done bool),
+) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		p.Trace(parser.StageTry, "Scan", t, fn)
+		start := p.Offset()
+
+		var state int
+		bs := make([]byte, 0)
+		for {
+			c := p.MayFail()
+
+			var b [1]byte
+			_, err := c.Read(b[:])
+			if errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				p.Trace(parser.StageFail, "Scan", t, fn, err)
+				return nil, err
+			}
+
+			consume, done := fn(b[0], &state)
+			if consume {
+				c.Keep()
+				bs = append(bs, b[0])
+			}
+
+			if done || !consume {
+				break
+			}
+		}
+
+		if len(bs) == 0 {
+			return nil, nil
+		}
+
+		m := &parser.Match{
+			Tag:     t,
+			Content: bs,
+			Start:   start,
+			End:     start + len(bs),
+		}
+		p.Trace(parser.StageGot, "Scan", t, fn, m)
+		return m, nil
+	}
+}
+
+// RunWhile returns a Matcher that consumes bytes from the input for as long as
+// cont returns true. The cont function is given the bytes matched so far along
+// with the next byte and decides whether that byte belongs to the run. The
+// first byte rejected is left in the input. If no bytes were consumed, it
+// returns nil.
+//
+// Unlike a BytePredicate, the decision may depend on what has already been
+// matched, such as stopping after a certain number of digits or once a running
+// value would exceed a limit.
+func RunWhile(
+	t token.Tag,
+	cont func(accumulated []byte, next byte) bool,
+) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		p.Trace(parser.StageTry, "RunWhile", t, cont)
+		start := p.Offset()
+
+		bs := make([]byte, 0)
+		for {
+			c := p.MayFail()
+
+			var b [1]byte
+			_, err := c.Read(b[:])
+			if errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				p.Trace(parser.StageFail, "RunWhile", t, cont, err)
+				return nil, err
+			}
+
+			// cap the slice so that appending to it cannot clobber bs
+			if !cont(bs[:len(bs):len(bs)], b[0]) {
+				break
+			}
+
+			c.Keep()
+			bs = append(bs, b[0])
+		}
+
+		if len(bs) == 0 {
+			return nil, nil
+		}
+
+		m := &parser.Match{
+			Tag:     t,
+			Content: bs,
+			Start:   start,
+			End:     start + len(bs),
+		}
+		p.Trace(parser.StageGot, "RunWhile", t, cont, m)
+		return m, nil
+	}
+}
+
+// TakeWhile returns a Matcher that consumes bytes from the input for as long as
+// they match the predicate. The returned Match has the given token.Tag and the
+// whole run as its Content, without a submatch for each byte. It always
+// matches, returning an empty Match if the first byte does not match. The
+// first byte that does not match is left in the input.
+func TakeWhile(t token.Tag, pred BytePredicate) parser.Matcher {
+	return takeWhile("TakeWhile", t, 0, pred)
+}
+
+// TakeWhile1 works just like TakeWhile, but at least one byte must match. If
+// none do, it returns nil.
+func TakeWhile1(t token.Tag, pred BytePredicate) parser.Matcher {
+	return takeWhile("TakeWhile1", t, 1, pred)
+}
+
+// takeWhile implements TakeWhile and TakeWhile1.
+func takeWhile(
+	name string,
+	t token.Tag,
+	min int,
+	pred BytePredicate,
+) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		start := p.Offset()
+
+		bs, err := readWhile(p, pred)
+		if err != nil {
+			p.Trace(parser.StageFail, name, t, pred, err)
+			return nil, err
+		}
+
+		if len(bs) < min {
+			return nil, nil
+		}
+
+		m := &parser.Match{
+			Tag:     t,
+			Content: bs,
+			Start:   start,
+			End:     start + len(bs),
+		}
+		p.Trace(parser.StageGot, name, t, pred, m)
+		return m, nil
+	}
+}
+
+// TakeUntil returns a Matcher that consumes bytes from the input until delim
+// would match. The delimiter is checked before each byte is consumed and is
+// left in the input. The returned Match has the given token.Tag and the bytes
+// before the delimiter as its Content, which may be empty. If the end of input
+// is reached before the delimiter is found, it returns nil and the input is
+// restored. See TakeUntilOrEOF to accept the end of input instead and
+// TakeThrough to consume the delimiter as well.
+func TakeUntil(t token.Tag, delim parser.Matcher) parser.MatcherFunc {
+	return takeUntil("TakeUntil", t, delim, false, false)
+}
+
+// TakeUntilOrEOF works just like TakeUntil, but also stops successfully at the
+// end of input.
+func TakeUntilOrEOF(t token.Tag, delim parser.Matcher) parser.MatcherFunc {
+	return takeUntil("TakeUntilOrEOF", t, delim, true, false)
+}
+
+// TakeThrough works just like TakeUntil, except that the delimiter is consumed
+// too. The Content of the returned Match is the bytes before the delimiter
+// followed by the Content of the delimiter. It has two submatches: the bytes
+// before the delimiter, with the given token.Tag, and the Match of the
+// delimiter.
+func TakeThrough(t token.Tag, delim parser.Matcher) parser.MatcherFunc {
+	return takeUntil("TakeThrough", t, delim, false, true)
+}
+
+// TakeThroughOrEOF works just like TakeThrough, but also stops successfully at
+// the end of input. In that case, the returned Match has only the one
+// submatch for the bytes read.
+func TakeThroughOrEOF(t token.Tag, delim parser.Matcher) parser.MatcherFunc {
+	return takeUntil("TakeThroughOrEOF", t, delim, true, true)
+}
+
+// takeUntil implements TakeUntil, TakeUntilOrEOF, TakeThrough, and
+// TakeThroughOrEOF.
+func takeUntil(
+	name string,
+	t token.Tag,
+	delim parser.Matcher,
+	orEOF bool,
+	consume bool,
+) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		p = p.MayFail()
+		start := p.Offset()
+
+		p.Trace(parser.StageTry, name, t, delim)
+
+		bs := make([]byte, 0)
+		var dm *parser.Match
+		for {
+			c := p.MayFail()
+			var err error
+			dm, err = delim.Match(c)
+			if err != nil {
+				p.Trace(parser.StageFail, name, t, delim, err)
+				return nil, err
+			}
+
+			if dm != nil {
+				if consume {
+					c.Keep()
+				}
+				break
+			}
+
+			var b [1]byte
+			_, err = p.Read(b[:])
+			if errors.Is(err, io.EOF) {
+				if orEOF {
+					break
+				}
+				return nil, nil
+			} else if err != nil {
+				p.Trace(parser.StageFail, name, t, delim, err)
+				return nil, err
+			}
+
+			bs = append(bs, b[0])
+		}
+
+		end := p.Offset()
+		p.Keep()
+
+		m := &parser.Match{
+			Tag:     t,
+			Content: bs,
+			Start:   start,
+			End:     start + len(bs),
+		}
+
+		if consume {
+			sms := []*parser.Match{m}
+			if dm != nil {
+				sms = append(sms, dm)
+			}
+
+			m = &parser.Match{
+				Tag:      t,
+				Content:  joinContent(sms),
+				Group:    map[string]*parser.Match{},
+				Submatch: sms,
+				Start:    start,
+				End:      end,
+			}
+		}
+
+		p.Trace(parser.StageGot, name, t, delim, m)
+		return m, nil
+	}
+}
+
+// readWhile consumes bytes from the input for as long as they match the
+// predicate and returns them. The first byte that does not match is left in
+// the input.
+func readWhile(p *parser.Input, pred BytePredicate) ([]byte, error) {
+	// scan ahead without copying, then consume the run all at once, or a piece
+	// at a time if the run is longer than the buffer can hold
+	bs := []byte{}
+	n := 0
+	for {
+		pbs, err := p.PeekRef(n + 1)
+		if len(pbs) <= n {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			if n == 0 {
+				return nil, err
+			}
+
+			// keep what was scanned so far to make room for the rest
+			if bs, err = readMore(p, bs, n); err != nil {
+				return nil, err
+			}
+
+			n = 0
+			continue
+		}
+
+		if !pred(pbs[n]) {
+			break
+		}
+
+		n++
+	}
+
+	return readMore(p, bs, n)
+}
+
+// readMore consumes the next n bytes from the input and appends them to bs.
+func readMore(p *parser.Input, bs []byte, n int) ([]byte, error) {
+	if n == 0 {
+		return bs, nil
+	}
+
+	l := len(bs)
+	bs = append(bs, make([]byte, n)...)
+
+	k := p.MayFail()
+	if _, err := k.Read(bs[l:]); err != nil {
+		return nil, err
+	}
+	k.Keep()
+
+	return bs, nil
+}
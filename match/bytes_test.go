@@ -0,0 +1,509 @@
+package match_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/gordy/match"
+	"github.com/zostay/gordy/parser"
+	"github.com/zostay/gordy/token"
+)
+
+func TestOneByte(t *testing.T) {
+	t.Parallel()
+
+	digit := match.OneByte(token.Literal, match.BytesInRange('0', '9'))
+
+	p := parser.New(strings.NewReader("7x"))
+	m, err := digit.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "7", string(m.Content))
+
+	// fails on x without consuming it
+	m, err = digit.Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	m, err = match.OneByte(token.Literal, match.BytesInSet('x')).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "x", string(m.Content))
+
+	// fails at the end of input
+	m, err = digit.Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestNBytes(t *testing.T) {
+	t.Parallel()
+
+	digits := match.BytesInRange('0', '9')
+	three := match.NBytes(token.Literal, 3, 3, digits)
+
+	p := parser.New(strings.NewReader("123"))
+	m, err := three.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "123", string(m.Content))
+
+	p = parser.New(strings.NewReader("12"))
+	m, err = three.Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	p = parser.New(strings.NewReader("1234"))
+	m, err = three.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "123", string(m.Content))
+
+	m, err = three.Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	m, err = match.OneByte(token.Literal, digits).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "4", string(m.Content))
+}
+
+func TestNBytes_Range(t *testing.T) {
+	t.Parallel()
+
+	digits := match.BytesInRange('0', '9')
+
+	tests := []struct {
+		name     string
+		from, to int
+		input    string
+		want     string
+		matched  bool
+	}{
+		{"from==to exact", 2, 2, "123", "12", true},
+		{"from==to short", 2, 2, "1x", "", false},
+		{"from<to max", 1, 3, "12345", "123", true},
+		{"from<to between", 1, 3, "12x", "12", true},
+		{"from<to short", 2, 3, "1x", "", false},
+		{"from==0 none", 0, 2, "x", "", true},
+		{"from==0 some", 0, 2, "1x", "1", true},
+		{"from==0 at end", 0, 2, "", "", true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := match.NBytes(token.Literal, tt.from, tt.to, digits).Match(p)
+			assert.NoError(t, err)
+			if !tt.matched {
+				assert.Nil(t, m)
+				return
+			}
+
+			require.NotNil(t, m)
+			assert.Equal(t, tt.want, string(m.Content))
+		})
+	}
+}
+
+// scanHex recognizes hexadecimal literals like 0x1f.
+func scanHex(b byte, state *int) (bool, bool) {
+	switch *state {
+	case 0:
+		*state = 1
+		return b == '0', b != '0'
+	case 1:
+		*state = 2
+		return b == 'x', b != 'x'
+	default:
+		isHex := (b >= '0' && b <= '9') || (b >= 'a' && b <= 'f')
+		return isHex, !isHex
+	}
+}
+
+func TestScan(t *testing.T) {
+	t.Parallel()
+
+	hex := match.Scan(token.Literal, scanHex)
+
+	p := parser.New(strings.NewReader("0x1fz"))
+	m, err := hex.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "0x1f", string(m.Content))
+
+	m, err = match.OneByte(token.Literal, match.BytesInSet('z')).Match(p)
+	assert.NoError(t, err)
+	assert.NotNil(t, m)
+
+	p = parser.New(strings.NewReader("0x1f"))
+	m, err = hex.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "0x1f", string(m.Content))
+
+	p = parser.New(strings.NewReader("z"))
+	m, err = hex.Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestScan_Done(t *testing.T) {
+	t.Parallel()
+
+	// consume exactly two bytes
+	two := match.Scan(token.Literal, func(b byte, state *int) (bool, bool) {
+		*state++
+		return true, *state == 2
+	})
+
+	p := parser.New(strings.NewReader("abc"))
+	m, err := two.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "ab", string(m.Content))
+}
+
+func TestRunWhile(t *testing.T) {
+	t.Parallel()
+
+	isDigit := func(b byte) bool { return b >= '0' && b <= '9' }
+
+	// at most three digits
+	three := match.RunWhile(token.Literal, func(acc []byte, b byte) bool {
+		return len(acc) < 3 && isDigit(b)
+	})
+
+	// digits while the value stays at or below 255
+	octet := match.RunWhile(token.Literal, func(acc []byte, b byte) bool {
+		if !isDigit(b) {
+			return false
+		}
+
+		n := 0
+		for _, d := range append(acc, b) {
+			n = n*10 + int(d-'0')
+		}
+		return n <= 255
+	})
+
+	tests := []struct {
+		name  string
+		mtch  parser.Matcher
+		input string
+		want  string
+	}{
+		{"three of many", three, "12345", "123"},
+		{"three of few", three, "12x", "12"},
+		{"three of none", three, "x", ""},
+		{"octet in range", octet, "255.", "255"},
+		{"octet over", octet, "256", "25"},
+		{"octet short", octet, "9", "9"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := tt.mtch.Match(p)
+			assert.NoError(t, err)
+			if tt.want == "" {
+				assert.Nil(t, m)
+				return
+			}
+
+			require.NotNil(t, m)
+			assert.Equal(t, tt.want, string(m.Content))
+		})
+	}
+}
+
+func TestTakeWhile(t *testing.T) {
+	t.Parallel()
+
+	digits := match.BytesInRange('0', '9')
+
+	tests := []struct {
+		name    string
+		mtch    parser.Matcher
+		input   string
+		want    string
+		matched bool
+	}{
+		{"TakeWhile run", match.TakeWhile(token.Literal, digits), "123x", "123", true},
+		{"TakeWhile all", match.TakeWhile(token.Literal, digits), "123", "123", true},
+		{"TakeWhile none", match.TakeWhile(token.Literal, digits), "x", "", true},
+		{"TakeWhile empty", match.TakeWhile(token.Literal, digits), "", "", true},
+		{"TakeWhile1 run", match.TakeWhile1(token.Literal, digits), "123x", "123", true},
+		{"TakeWhile1 none", match.TakeWhile1(token.Literal, digits), "x", "", false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := tt.mtch.Match(p)
+			assert.NoError(t, err)
+			if !tt.matched {
+				assert.Nil(t, m)
+			} else {
+				require.NotNil(t, m)
+				assert.Equal(t, tt.want, string(m.Content))
+				assert.Empty(t, m.Submatch)
+			}
+
+			// the byte after the run is left in the input
+			rest := tt.input[len(tt.want):]
+			bs := make([]byte, len(rest))
+			n, _ := p.Read(bs)
+			assert.Equal(t, rest, string(bs[:n]))
+		})
+	}
+}
+
+func TestTakeWhile_LongRun(t *testing.T) {
+	t.Parallel()
+
+	as := match.BytesInSet('a')
+
+	tests := []struct {
+		name string
+		p    *parser.Input
+		n    int
+	}{
+		{"small buffer", parser.NewSize(strings.NewReader(strings.Repeat("a", 100)+"x"), 16), 100},
+		{"default buffer", parser.New(strings.NewReader(strings.Repeat("a", 10000) + "x")), 10000},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := match.TakeWhile(token.Literal, as).Match(tt.p)
+			assert.NoError(t, err)
+			require.NotNil(t, m)
+			assert.Equal(t, strings.Repeat("a", tt.n), string(m.Content))
+			assert.Equal(t, tt.n, m.End)
+
+			c, err := tt.p.ReadByte()
+			assert.NoError(t, err)
+			assert.Equal(t, byte('x'), c)
+		})
+	}
+}
+
+func BenchmarkTakeWhile(b *testing.B) {
+	// ten times the default buffer size
+	in := strings.Repeat("0123456789", 4096) + "x"
+	digits := match.BytesInRange('0', '9')
+
+	for _, bc := range []struct {
+		name string
+		mtch parser.Matcher
+	}{
+		{"TakeWhile", match.TakeWhile(token.Literal, digits)},
+		{"ManyOneByte", match.Many(token.Literal, 0,
+			match.OneByte(token.Literal, digits))},
+	} {
+		bc := bc
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				p := parser.New(strings.NewReader(in))
+				if m, err := bc.mtch.Match(p); err != nil || m.Length() != 40960 {
+					b.Fatal("failed to match", err)
+				}
+			}
+		})
+	}
+}
+
+func TestTakeUntil(t *testing.T) {
+	t.Parallel()
+
+	newline := match.ByteSlice(token.Literal, []byte("\n"))
+	endComment := match.ByteSlice(token.Literal, []byte("*/"))
+
+	tests := []struct {
+		name    string
+		mtch    parser.Matcher
+		input   string
+		want    string
+		matched bool
+	}{
+		{"newline", match.TakeUntil(token.Literal, newline), "abc\ndef", "abc", true},
+		{"newline first", match.TakeUntil(token.Literal, newline), "\nabc", "", true},
+		{"newline missing", match.TakeUntil(token.Literal, newline), "abc", "", false},
+		{"comment", match.TakeUntil(token.Literal, endComment), " a * b */ c", " a * b ", true},
+		{"comment missing", match.TakeUntil(token.Literal, endComment), " a * b *", "", false},
+		{"or EOF found", match.TakeUntilOrEOF(token.Literal, newline), "abc\ndef", "abc", true},
+		{"or EOF", match.TakeUntilOrEOF(token.Literal, newline), "abc", "abc", true},
+		{"through newline", match.TakeThrough(token.Literal, newline), "abc\ndef", "abc\n", true},
+		{"through newline first", match.TakeThrough(token.Literal, newline), "\nabc", "\n", true},
+		{"through newline missing", match.TakeThrough(token.Literal, newline), "abc", "", false},
+		{"through comment", match.TakeThrough(token.Literal, endComment), " a * b */ c", " a * b */", true},
+		{"through or EOF found", match.TakeThroughOrEOF(token.Literal, newline), "abc\ndef", "abc\n", true},
+		{"through or EOF", match.TakeThroughOrEOF(token.Literal, newline), "abc", "abc", true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := tt.mtch.Match(p)
+			assert.NoError(t, err)
+			if !tt.matched {
+				assert.Nil(t, m)
+			} else {
+				require.NotNil(t, m)
+				assert.Equal(t, tt.want, string(m.Content))
+			}
+
+			// the delimiter is left in the input unless taken through, or all
+			// of it on failure
+			rest := tt.input[len(tt.want):]
+			bs := make([]byte, len(rest))
+			n, _ := p.Read(bs)
+			assert.Equal(t, rest, string(bs[:n]))
+		})
+	}
+}
+
+func TestTakeThrough_Submatch(t *testing.T) {
+	t.Parallel()
+
+	endComment := match.ByteSlice(token.Literal, []byte("*/"))
+
+	p := parser.New(strings.NewReader("/* a */ b"))
+	_, err := p.Read(make([]byte, 2))
+	require.NoError(t, err)
+
+	m, err := match.TakeThrough(token.Literal, endComment).Match(p)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, " a */", m.Text())
+	assert.Equal(t, 2, m.Start)
+	assert.Equal(t, 7, m.End)
+
+	require.Len(t, m.Submatch, 2)
+	assert.Equal(t, " a ", m.Submatch[0].Text())
+	assert.Equal(t, 5, m.Submatch[0].End)
+	assert.Equal(t, "*/", m.Submatch[1].Text())
+	assert.Equal(t, 7, p.Offset())
+}
+
+func TestCharClass(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		spec  string
+		match string
+		miss  string
+	}{
+		{"a-zA-Z0-9_", "azAZ09_", "-^ !"},
+		{"abc", "abc", "dA-"},
+		{"^0-9", "a -^", "059"},
+		{"\\-\\^", "-^", "\\a"},
+		{"^\\^", "a-", "^"},
+		{"-a", "-a", "b"},
+		{"a-", "-a", "b"},
+		{"+\\--/", "+-./", ",0"},
+		{"\\\\", "\\", "a"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.spec, func(t *testing.T) {
+			t.Parallel()
+
+			cc, err := match.CharClass(token.Literal, tt.spec)
+			require.NoError(t, err)
+
+			for _, c := range []byte(tt.match) {
+				m, err := cc.Match(parser.New(strings.NewReader(string(c))))
+				assert.NoError(t, err)
+				assert.NotNil(t, m, "%q matches", c)
+			}
+
+			for _, c := range []byte(tt.miss) {
+				m, err := cc.Match(parser.New(strings.NewReader(string(c))))
+				assert.NoError(t, err)
+				assert.Nil(t, m, "%q does not match", c)
+			}
+		})
+	}
+}
+
+func TestCharClass_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, spec := range []string{"", "^", "z-a", "abc\\"} {
+		cc, err := match.CharClass(token.Literal, spec)
+		assert.Nil(t, cc, spec)
+		assert.ErrorIs(t, err, match.ErrCharClass, spec)
+	}
+}
+
+func BenchmarkNBytes(b *testing.B) {
+	in := strings.Repeat("a", 1000)
+	run := match.NBytes(token.Literal, 0, len(in), match.BytesInSet('a'))
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := parser.New(strings.NewReader(in))
+		if m, err := run.Match(p); err != nil || m.Length() != len(in) {
+			b.Fatal("failed to match", err)
+		}
+	}
+}
+
+func TestBytes_CombineKeepsRange(t *testing.T) {
+	t.Parallel()
+
+	three := match.NBytes(token.Literal, 3, 3, match.BytesInRange('0', '9')).(*match.Bytes)
+	hex := match.OneByte(token.Literal, match.BytesInRange('a', 'f')).(*match.Bytes)
+	zero := match.OneByte(token.Literal, match.BytesInSet('0')).(*match.Bytes)
+
+	tests := []struct {
+		name  string
+		mtch  parser.Matcher
+		input string
+		want  string
+	}{
+		{"AndAlso", three.AndAlso(hex), "1a2b3c", "1a2"},
+		{"AndAlso short", three.AndAlso(hex), "1a", ""},
+		{"ButNot", three.ButNot(zero), "12345", "123"},
+		{"ButNot short", three.ButNot(zero), "1203", ""},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := tt.mtch.Match(parser.New(strings.NewReader(tt.input)))
+			assert.NoError(t, err)
+			if tt.want == "" {
+				assert.Nil(t, m)
+				return
+			}
+
+			require.NotNil(t, m)
+			assert.Equal(t, tt.want, string(m.Content))
+		})
+	}
+}
@@ -0,0 +1,27 @@
+// Package match provides generators for the parser.Matcher objects used to
+// build a recursive descent parser out of smaller parts.
+//
+// # Terminators
+//
+// Some matchers work by reading up to a terminator: they look ahead for a
+// delimiter matcher and stop when it matches. Whether the terminator is
+// consumed is given by the name of the matcher, so a grammar can always tell:
+//
+//   - A matcher named with "Until", such as TakeUntil, only peeks the
+//     terminator and never consumes it. The input is left positioned at the
+//     start of the terminator so the next matcher in the grammar sees it.
+//   - A matcher named with "Through", such as TakeThrough, consumes the
+//     terminator along with everything before it.
+//
+// For example, these two match a C comment the same way:
+//
+//	open, close := ByteSlice(t, []byte("/*")), ByteSlice(t, []byte("*/"))
+//	comment := Seq(t, open, TakeUntil(t, close), close)
+//	comment = Seq(t, open, TakeThrough(t, close))
+//
+// Matchers that stop at the end of a line, such as OrRestOfLine and
+// FoldedValue, follow the "Until" rule and leave the line break in the input.
+// Mixing rules that do and do not consume their terminator is a common source
+// of grammar bugs, so new terminator-based matchers should come as a pair of
+// "Until" and "Through" variants rather than taking an option.
+package match
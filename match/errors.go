@@ -0,0 +1,33 @@
+package match
+
+import "errors"
+
+var (
+	// ErrDuplicate is returned (wrapped) by matchers that enforce uniqueness
+	// when a value is matched a second time.
+	ErrDuplicate = errors.New("duplicate value")
+
+	// ErrTrailingInput is returned (wrapped) by matchers that must match all
+	// of their input when some input remains after matching.
+	ErrTrailingInput = errors.New("trailing input")
+
+	// ErrUnbalanced is returned (wrapped) by CloseTag and TagBalance when
+	// open and close tags do not pair up.
+	ErrUnbalanced = errors.New("unbalanced tags")
+
+	// ErrUndefinedRule is returned when a Rule is matched before its Matcher
+	// has been set.
+	ErrUndefinedRule = errors.New("undefined rule")
+
+	// ErrNoProgress is returned (wrapped) by ManySafe when an iteration
+	// matches without consuming any input.
+	ErrNoProgress = errors.New("no progress")
+
+	// ErrCharClass is returned (wrapped) by CharClass when the spec is not a
+	// valid character class.
+	ErrCharClass = errors.New("invalid character class")
+
+	// ErrUnknownCategory is returned (wrapped) by RunesInCategory when the
+	// name is neither a Unicode category nor a script.
+	ErrUnknownCategory = errors.New("unknown Unicode category")
+)
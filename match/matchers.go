@@ -1,20 +1,40 @@
 package match
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"unicode"
 	"unicode/utf8"
 
 	"github.com/zostay/gordy/parser"
 	"github.com/zostay/gordy/token"
 )
 
-// selectLongest is an internal helper used to find the longest match out of a
-// list of matches.
-func selectLongest(ms []*parser.Match) int {
-	var ln int
+// consumed returns the number of bytes of input the match covers, from Start to
+// End. This may be more than its Length when some of the input was matched by
+// Skip.
+func consumed(m *parser.Match) int {
+	return m.End - m.Start
+}
+
+// selectLongest is an internal helper used to find the match out of a list of
+// matches that consumed the most input. It returns -1 if every match in the
+// list is nil. When two matches consumed the same amount, the later one is
+// selected only if prefer is not nil and prefer(later, earlier) returns true.
+// Otherwise, the first wins.
+func selectLongest(ms []*parser.Match, prefer func(a, b *parser.Match) bool) int {
+	ln := -1
 	var lm *parser.Match
 
 	for n, m := range ms {
-		if lm == nil || m.Length() > lm.Length() {
+		if m == nil {
+			continue
+		}
+
+		if lm == nil || consumed(m) > consumed(lm) ||
+			(consumed(m) == consumed(lm) && prefer != nil && prefer(m, lm)) {
 			ln = n
 			lm = m
 		}
@@ -25,24 +45,100 @@ func selectLongest(ms []*parser.Match) int {
 
 // Longest returns a Matcher that tries all the given matchers against the
 // current input. It will keep the longest match found and discard the rest. It
-// that longest Match.
+// returns that longest Match. A match is as long as the input it consumed,
+// from its Start to its End, so input dropped from the Content by Skip still
+// counts. When more than one matcher matches the longest length, the first of
+// them in the list wins. Use LongestPreferLast to have the last win instead or
+// LongestBy to break ties some other way.
 func Longest(ms ...parser.Matcher) parser.MatcherFunc {
+	return LongestDebug(false, ms...)
+}
+
+// LongestBy returns a Matcher that works just like Longest, but breaks ties
+// using prefer. When two matches have the same length, prefer(a, b) is called
+// with the later match as a and the best match so far as b. If it returns
+// true, a is kept instead of b.
+func LongestBy(
+	prefer func(a, b *parser.Match) bool,
+	ms ...parser.Matcher,
+) parser.MatcherFunc {
+	return longest(false, prefer, ms)
+}
+
+// LongestPreferLast returns a Matcher that works just like Longest, except that
+// when more than one matcher matches the longest length, the last of them in
+// the list wins.
+func LongestPreferLast(ms ...parser.Matcher) parser.MatcherFunc {
+	return longest(false, preferLater, ms)
+}
+
+// preferLater is the tie-breaker for LongestPreferLast.
+func preferLater(a, b *parser.Match) bool {
+	return true
+}
+
+// LongestDebug returns a Matcher that works just like Longest. However, when
+// debug is true, the winning Match is wrapped in a parent Match with the same
+// Tag and Content, whose only Submatch is the winner and whose Made field is a
+// map[int]int mapping the index of each alternative to the number of bytes of
+// input it consumed, or -1 if it did not match. The winner itself is left
+// untouched, so any value set by Map is kept. This is helpful for understanding
+// why an ambiguous grammar picked an unexpected alternative.
+func LongestDebug(debug bool, ms ...parser.Matcher) parser.MatcherFunc {
+	return longest(debug, nil, ms)
+}
+
+// longest implements Longest, LongestBy, and LongestDebug.
+func longest(
+	debug bool,
+	prefer func(a, b *parser.Match) bool,
+	ms []parser.Matcher,
+) parser.MatcherFunc {
 	return func(p *parser.Input) (*parser.Match, error) {
 		msm := make([]*parser.Match, len(ms))
 		msp := make([]*parser.Input, len(ms))
 
+		// keep a Commit in one alternative from discarding input the others
+		// have yet to read
+		mark := p.Mark()
 		for i, mp := range ms {
-			p := p.MayFail()
-			m, err := mp.Match(p)
+			c := p.MayFail()
+			m, err := mp.Match(c)
 			if err != nil {
+				p.Release(mark)
 				return nil, err
 			}
 
 			msm[i] = m
-			msp[i] = p
+			msp[i] = c
 		}
+		p.Release(mark)
+
+		if w := selectLongest(msm, prefer); w != -1 {
+			if debug {
+				lengths := make(map[int]int, len(msm))
+				for i, m := range msm {
+					if m == nil {
+						lengths[i] = -1
+						continue
+					}
+					lengths[i] = consumed(m)
+				}
+				msp[w].Keep()
+
+				m := &parser.Match{
+					Tag:      msm[w].Tag,
+					Content:  msm[w].Content,
+					Submatch: []*parser.Match{msm[w]},
+					Made:     lengths,
+					Start:    msm[w].Start,
+					End:      msm[w].End,
+				}
+
+				p.Trace(parser.StageGot, "MatchLongest", w, m)
+				return m, nil
+			}
 
-		if w := selectLongest(msm); w != -1 {
 			p.Trace(parser.StageGot, "MatchLongest", w, msm[w])
 			msp[w].Keep()
 			return msm[w], nil
@@ -55,80 +151,186 @@ func Longest(ms ...parser.Matcher) parser.MatcherFunc {
 // ManyWithSep returns a matcher that matches the given matcher against the
 // input provided that the separator matcher matches in between. It returns a
 // match containing those matches. If fewer than min matches are present, the
-// match returns no match.
+// match returns no match. A separator that is not followed by a match is not
+// consumed.
 func ManyWithSep(
 	t token.Tag,
 	min int,
 	mtch parser.Matcher,
 	sep parser.Matcher,
+) parser.MatcherFunc {
+	return sepBy("MatchManyWithSep", t, min, mtch, sep, false)
+}
+
+// SepEndBy returns a matcher that works just like ManyWithSep, except that a
+// single separator following the last match is also consumed if present. The
+// trailing separator is part of the Content of the returned Match.
+func SepEndBy(
+	t token.Tag,
+	min int,
+	mtch parser.Matcher,
+	sep parser.Matcher,
+) parser.MatcherFunc {
+	return sepBy("MatchSepEndBy", t, min, mtch, sep, true)
+}
+
+// sepBy implements ManyWithSep and SepEndBy.
+func sepBy(
+	name string,
+	t token.Tag,
+	min int,
+	mtch parser.Matcher,
+	sep parser.Matcher,
+	trailing bool,
 ) parser.MatcherFunc {
 	return func(p *parser.Input) (*parser.Match, error) {
+		p = p.MayFail()
+		start := p.Offset()
+
 		mbs := make([]*parser.Match, 0)
-		ms := make([]*parser.Match, 0)
-		totalLen := 0
 
-		p.Trace(parser.StageTry, "MatchManyWithSep", t, min, mtch, sep)
+		// every match and separator in order, for building the content
+		parts := make([]*parser.Match, 0)
+
+		p.Trace(parser.StageTry, name, t, min, mtch, sep)
 
 		for {
-			var pms [2]*parser.Match
-			if len(ms) > 0 {
-				m, err := sep.Match(p)
+			c := p.MayFail()
+
+			var sm *parser.Match
+			if len(mbs) > 0 {
+				var err error
+				sm, err = sep.Match(c)
 				if err != nil {
-					p.Trace(parser.StageFail, "MatchManyWithSep", t, min, mtch, sep, err)
+					p.Trace(parser.StageFail, name, t, min, mtch, sep, err)
 					return nil, err
 				}
 
-				if m != nil {
-					pms[0] = m
-				} else {
+				if sm == nil {
 					break
 				}
 			}
 
-			m, err := mtch.Match(p)
+			m, err := mtch.Match(c)
 			if err != nil {
-				p.Trace(parser.StageFail, "MatchManyWithSep", t, min, mtch, sep, err)
+				p.Trace(parser.StageFail, name, t, min, mtch, sep, err)
 				return nil, err
 			}
 
-			if m != nil {
-				pms[1] = m
-
-				if len(ms) > 0 {
-					totalLen += len(pms[0].Content)
-				}
-				totalLen += len(pms[1].Content)
+			if m == nil {
+				break
+			}
 
-				mbs = append(mbs, m)
-				if len(ms) > 0 {
-					ms = append(ms, pms[0], pms[1])
-				} else {
-					ms = append(ms, pms[1])
-				}
+			c.Keep()
 
-				continue
+			if sm != nil {
+				parts = append(parts, sm)
 			}
-
-			break
+			parts = append(parts, m)
+			mbs = append(mbs, m)
 		}
 
 		if len(mbs) < min {
 			return nil, nil
 		}
 
-		content := make([]byte, 0, totalLen)
-		for _, m := range ms {
+		if trailing && len(mbs) > 0 {
+			sm, err := TryAndKeep(sep).Match(p)
+			if err != nil {
+				p.Trace(parser.StageFail, name, t, min, mtch, sep, err)
+				return nil, err
+			}
+
+			if sm != nil {
+				parts = append(parts, sm)
+			}
+		}
+
+		end := p.Offset()
+		p.Keep()
+
+		m := &parser.Match{
+			Tag:      t,
+			Content:  joinContent(parts),
+			Start:    start,
+			End:      end,
+			Group:    map[string]*parser.Match{},
+			Submatch: mbs,
+		}
+
+		p.Trace(parser.StageGot, name, t, min, mtch, sep, m)
+		return m, nil
+	}
+}
+
+// EndBy returns a matcher that matches the given matcher against the input as
+// many times as possible, where each match must be followed by the separator.
+// A match that is not followed by a separator is not consumed. The returned
+// Match has the matches as submatches and its Content includes the
+// separators. If fewer than min matches are present, the match returns no
+// match.
+func EndBy(
+	t token.Tag,
+	min int,
+	mtch parser.Matcher,
+	sep parser.Matcher,
+) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		p = p.MayFail()
+		start := p.Offset()
+
+		content := make([]byte, 0)
+		mbs := make([]*parser.Match, 0)
+
+		p.Trace(parser.StageTry, "MatchEndBy", t, min, mtch, sep)
+
+		for {
+			c := p.MayFail()
+
+			m, err := mtch.Match(c)
+			if err != nil {
+				p.Trace(parser.StageFail, "MatchEndBy", t, min, mtch, sep, err)
+				return nil, err
+			}
+
+			if m == nil {
+				break
+			}
+
+			sm, err := sep.Match(c)
+			if err != nil {
+				p.Trace(parser.StageFail, "MatchEndBy", t, min, mtch, sep, err)
+				return nil, err
+			}
+
+			if sm == nil {
+				break
+			}
+
+			c.Keep()
+
 			content = append(content, m.Content...)
+			content = append(content, sm.Content...)
+			mbs = append(mbs, m)
+		}
+
+		if len(mbs) < min {
+			return nil, nil
 		}
 
+		end := p.Offset()
+		p.Keep()
+
 		m := &parser.Match{
 			Tag:      t,
 			Content:  content,
+			Start:    start,
+			End:      end,
 			Group:    map[string]*parser.Match{},
 			Submatch: mbs,
 		}
 
-		p.Trace(parser.StageGot, "MatchManyWithSep", t, min, mtch, sep, m)
+		p.Trace(parser.StageGot, "MatchEndBy", t, min, mtch, sep, m)
 		return m, nil
 	}
 }
@@ -142,8 +344,8 @@ func Many(
 	mtch parser.Matcher,
 ) parser.MatcherFunc {
 	return func(p *parser.Input) (*parser.Match, error) {
-		content := make([]byte, 0)
-		ms := make([]*parser.Match, 0, min)
+		start := p.Offset()
+		ms := make([]*parser.Match, 0)
 
 		for {
 			m, err := mtch.Match(p)
@@ -153,8 +355,6 @@ func Many(
 
 			if m != nil {
 				ms = append(ms, m)
-				content = append(content, m.Content...)
-
 				continue
 			}
 
@@ -167,9 +367,11 @@ func Many(
 
 		m := &parser.Match{
 			Tag:      t,
-			Content:  content,
+			Content:  joinContent(ms),
 			Group:    map[string]*parser.Match{},
 			Submatch: ms,
+			Start:    start,
+			End:      p.Offset(),
 		}
 
 		p.Trace(parser.StageGot, "MatchMany", t, min, mtch, m)
@@ -177,168 +379,1027 @@ func Many(
 	}
 }
 
-// First returns a matcher that will try each match and immediately returns on
-// the first one tried that succeeds. Returns no match if none succeed.
-func First(mtchs ...parser.Matcher) parser.MatcherFunc {
+// ManyFunc returns a Matcher that works like Many, except that each match is
+// passed to yield as soon as it is made and is not kept afterward. This allows
+// a long run of repetitions, such as the rows of a large file, to be matched
+// without building a Match for all of them at once. If yield returns an error,
+// matching stops and the error is returned.
+//
+// The returned Match has the given token.Tag, the Start and End of all the
+// matches, and the number of matches in Made as an int. Its Content and
+// Submatch are empty. If the number of matches is fewer than min, it returns
+// nil, but yield has already been called for the matches made.
+//
+// Each match is kept as it is made, so when used on the root Input, the input
+// matched is discarded from the buffer as it is read, too.
+func ManyFunc(
+	t token.Tag,
+	min int,
+	mtch parser.Matcher,
+	yield func(*parser.Match) error,
+) parser.MatcherFunc {
 	return func(p *parser.Input) (*parser.Match, error) {
-		for _, mtch := range mtchs {
-			p := p.MayFail()
+		p.Trace(parser.StageTry, "MatchManyFunc", t, min, mtch)
+		start := p.Offset()
 
-			m, err := mtch.Match(p)
+		n := 0
+		for {
+			c := p.MayFail()
+			m, err := mtch.Match(c)
 			if err != nil {
+				p.Trace(parser.StageFail, "MatchManyFunc", t, min, mtch, err)
 				return nil, err
 			}
 
-			if m != nil {
-				return m, nil
+			if m == nil {
+				break
+			}
+
+			c.Keep()
+			n++
+
+			if err := yield(m); err != nil {
+				p.Trace(parser.StageFail, "MatchManyFunc", t, min, mtch, err)
+				return nil, err
 			}
 		}
 
-		return nil, nil
+		if n < min {
+			return nil, nil
+		}
+
+		m := &parser.Match{
+			Tag:   t,
+			Made:  n,
+			Group: map[string]*parser.Match{},
+			Start: start,
+			End:   p.Offset(),
+		}
+
+		p.Trace(parser.StageGot, "MatchManyFunc", t, min, mtch, m)
+		return m, nil
 	}
 }
 
-// Seq returns a Matcher that applies each passed Matcher in turn against the
-// input. Returns with no match immediately if any Matcher in the sequence
-// fails. Returns the whole Match if every Matcher succeeds.
-func Seq(
+// joinContent returns the Content of the given matches joined together. It
+// makes a single allocation sized to fit, or none if there is no content.
+func joinContent(ms []*parser.Match) []byte {
+	n := 0
+	for _, m := range ms {
+		n += len(m.Content)
+	}
+
+	content := make([]byte, 0, n)
+	for _, m := range ms {
+		content = append(content, m.Content...)
+	}
+	return content
+}
+
+// ManySafe returns a Matcher that works like Many, except that each iteration
+// must consume at least one byte of input. An iteration that matches without
+// consuming input would repeat forever, so when one happens, ManySafe returns
+// an error wrapping ErrNoProgress so that the grammar bug is found. Use
+// ManySafeLenient to stop quietly instead. The input read by a failed
+// iteration is restored. If the number of matches is fewer than min, it
+// returns nil and the input is restored.
+func ManySafe(
 	t token.Tag,
-	mtchs ...parser.Matcher,
+	min int,
+	mtch parser.Matcher,
+) parser.MatcherFunc {
+	return manySafe("MatchManySafe", t, min, mtch, true)
+}
+
+// ManySafeLenient works just like ManySafe, except that when an iteration
+// matches without consuming input, it stops repeating as if that iteration had
+// failed to match instead of returning an error.
+func ManySafeLenient(
+	t token.Tag,
+	min int,
+	mtch parser.Matcher,
+) parser.MatcherFunc {
+	return manySafe("MatchManySafeLenient", t, min, mtch, false)
+}
+
+// manySafe implements ManySafe and ManySafeLenient.
+func manySafe(
+	name string,
+	t token.Tag,
+	min int,
+	mtch parser.Matcher,
+	strict bool,
 ) parser.MatcherFunc {
 	return func(p *parser.Input) (*parser.Match, error) {
-		ms := make([]*parser.Match, len(mtchs))
-		for i, mtch := range mtchs {
-			m, err := mtch.Match(p)
-			if err != nil || m == nil {
+		p = p.MayFail()
+		start := p.Offset()
+
+		ms := make([]*parser.Match, 0, min)
+
+		for {
+			c := p.MayFail()
+			before := c.Offset()
+
+			m, err := mtch.Match(c)
+			if err != nil {
+				p.Trace(parser.StageFail, name, t, min, mtch, err)
 				return nil, err
 			}
 
-			ms[i] = m
+			if m == nil {
+				break
+			}
+
+			if c.Offset() == before {
+				if !strict {
+					break
+				}
+
+				err := fmt.Errorf("%w: matched nothing at offset %d after %d matches",
+					ErrNoProgress, before, len(ms))
+				p.Trace(parser.StageFail, name, t, min, mtch, err)
+				return nil, err
+			}
+
+			c.Keep()
+			ms = append(ms, m)
 		}
 
-		return &parser.Match{
+		if len(ms) < min {
+			return nil, nil
+		}
+
+		end := p.Offset()
+		p.Keep()
+
+		m := &parser.Match{
 			Tag:      t,
+			Content:  joinContent(ms),
+			Group:    map[string]*parser.Match{},
 			Submatch: ms,
-		}, nil
+			Start:    start,
+			End:      end,
+		}
+
+		p.Trace(parser.StageGot, name, t, min, mtch, m)
+		return m, nil
 	}
 }
 
-// SeqNamed returns a Matcher that applies each named Matcher in turn against
-// the input. Returns with no match immediately if any Matcher in the sequence
-// fails to match. Returns the whole Match if every Matcher succeeds. The
-// Matchers passed must all have a name passed in the arguments. (If you want a
-// submatch to be unnamed, pass the empty string.)
-func SeqNamed(
+// Repeat returns a Matcher that matches the given matcher one after another on
+// the input at least min times and at most max times. It stops after max
+// matches even if more would match. If fewer than min matches are found, it
+// returns nil and the input is restored. To match exactly n times, see Count.
+func Repeat(
 	t token.Tag,
-	ms ...any,
+	min, max int,
+	mtch parser.Matcher,
 ) parser.MatcherFunc {
 	return func(p *parser.Input) (*parser.Match, error) {
-		mps := make([]any, len(ms))
-		for i, mtch := range ms {
-			if i%2 == 0 {
-				continue
-			}
+		p = p.MayFail()
+		start := p.Offset()
+
+		ms := make([]*parser.Match, 0, min)
 
-			m, err := mtch.(parser.Matcher).Match(p)
-			if err != nil || m == nil {
+		for len(ms) < max {
+			c := p.MayFail()
+			m, err := mtch.Match(c)
+			if err != nil {
+				p.Trace(parser.StageFail, "MatchRepeat", t, min, max, mtch, err)
 				return nil, err
 			}
 
-			mps[i-1] = ms[i-1]
-			mps[i] = m
+			if m == nil {
+				break
+			}
+
+			c.Keep()
+			ms = append(ms, m)
 		}
 
-		return parser.BuildMatch(t, mps...), nil
-	}
-}
+		if len(ms) < min {
+			return nil, nil
+		}
 
-// ByteSlice returns a Matcher that returns Match when the given byte slice
-// matches the next bytes in the input.
-func ByteSlice(
-	t token.Tag,
-	bs []byte,
-) parser.Matcher {
-	byteMatchers := make([]parser.Matcher, 0, len(bs))
-	for _, b := range bs {
-		byteMatchers = append(
-			byteMatchers,
-			OneByte(token.Literal, BytesInSet(b)),
-		)
-	}
-	return Seq(t, byteMatchers...)
-}
+		end := p.Offset()
+		p.Keep()
 
-// RuneSlice returns a Matcher that returns Match when the given rune slice
-// matches the next runes in the input.
-func RuneSlice(
-	t token.Tag,
-	rs []rune,
-) parser.Matcher {
-	runeMatchers := make([]parser.Matcher, 0, len(rs))
-	for _, r := range rs {
-		runeMatchers = append(
-			runeMatchers,
-			OneRune(token.Literal, RunesInSet(r)),
-		)
-	}
-	return Seq(t, runeMatchers...)
-}
+		m := &parser.Match{
+			Tag:      t,
+			Content:  joinContent(ms),
+			Start:    start,
+			End:      end,
+			Group:    map[string]*parser.Match{},
+			Submatch: ms,
+		}
 
-// String returns a Matcher that returns a Match when the given string matches
-// the next runes in the input.
-func String(
-	t token.Tag,
-	s string,
-) parser.Matcher {
-	runeMatchers := make([]parser.Matcher, 0, utf8.RuneCountInString(s))
-	for len(s) > 0 {
-		r, size := utf8.DecodeRuneInString(s)
-		runeMatchers = append(
-			runeMatchers,
-			OneRune(token.Literal, RunesInSet(r)),
-		)
-		s = s[size:]
+		p.Trace(parser.StageGot, "MatchRepeat", t, min, max, mtch, m)
+		return m, nil
 	}
-	return Seq(t, runeMatchers...)
 }
 
-// Optional returns a Matcher that returns the Match when the called Matcher
-// matches, but also returns an empty Match when the called Matcher does not
-// match. The token.Tag on the empty Match is token.None.
-func Optional(
+// Count returns a Matcher that matches the given matcher exactly n times one
+// after another on the input. It will not consume more than n matches. If
+// fewer than n matches are found, it returns nil and the input is restored.
+func Count(
+	t token.Tag,
+	n int,
 	mtch parser.Matcher,
 ) parser.MatcherFunc {
+	return Repeat(t, n, n, mtch)
+}
+
+// First returns a matcher that will try each match and immediately returns on
+// the first one tried that succeeds. Returns no match if none succeed.
+func First(mtchs ...parser.Matcher) parser.MatcherFunc {
 	return func(p *parser.Input) (*parser.Match, error) {
-		m, err := TryAndKeep(mtch).Match(p)
-		if err != nil {
-			return nil, err
-		}
+		mark := p.Mark()
+		defer p.Release(mark)
 
-		if m != nil {
-			return m, nil
+		for _, mtch := range mtchs {
+			m, err := tryAt(p, mark, mtch)
+			if err != nil || m != nil {
+				return m, err
+			}
 		}
 
-		return &parser.Match{Tag: token.None}, nil
+		return nil, nil
 	}
 }
 
-// TryAndKeep returns a matcher that will call the given Matcher and try to
-// match against the input. On fail, input is restored to what it was before. On
-// success, input moves forward to whatever the Matcher consumed.
-func TryAndKeep(mtch parser.Matcher) parser.MatcherFunc {
+// tryAt matches mtch against p, which must be at the given Mark. If mtch fails
+// to match or returns an error, p is rewound to the Mark.
+func tryAt(
+	p *parser.Input,
+	mark parser.Mark,
+	mtch parser.Matcher,
+) (*parser.Match, error) {
+	m, err := mtch.Match(p)
+	if err == nil && m != nil {
+		return m, nil
+	}
+
+	if rerr := p.Rewind(mark); err == nil {
+		err = rerr
+	}
+	return nil, err
+}
+
+// Seq returns a Matcher that applies each passed Matcher in turn against the
+// input. Returns with no match immediately if any Matcher in the sequence
+// fails. Returns the whole Match if every Matcher succeeds. The Content of the
+// whole Match is the Content of each submatch, in order.
+func Seq(
+	t token.Tag,
+	mtchs ...parser.Matcher,
+) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		pt := partialsFor(p)
+		saved := pt.start()
+		start := p.Offset()
+
+		content := make([]byte, 0)
+		ms := make([]*parser.Match, len(mtchs))
+		for i, mtch := range mtchs {
+			pt.start()
+			at := p.Offset()
+			m, err := mtch.Match(p)
+			if err != nil {
+				return nil, err
+			}
+
+			if m == nil {
+				pt.fail(saved, t, nil, ms[:i], start, at)
+				return nil, nil
+			}
+
+			ms[i] = m
+			content = append(content, m.Content...)
+		}
+
+		pt.done(saved)
+		return &parser.Match{
+			Tag:      t,
+			Content:  content,
+			Submatch: ms,
+			Start:    start,
+			End:      p.Offset(),
+		}, nil
+	}
+}
+
+// Elem is an element of the sequence matched by SeqOptional. Use Required and
+// Maybe to build them.
+type Elem struct {
+	Matcher  parser.Matcher // the matcher for this element
+	Optional bool           // true if the sequence may match without it
+}
+
+// Required returns an Elem that must match for SeqOptional to match.
+func Required(mtch parser.Matcher) Elem {
+	return Elem{Matcher: mtch}
+}
+
+// Maybe returns an Elem that SeqOptional skips when it does not match.
+func Maybe(mtch parser.Matcher) Elem {
+	return Elem{Matcher: mtch, Optional: true}
+}
+
+// SeqOptional returns a Matcher that applies each element in turn against the
+// input, like Seq. When an optional element does not match, it is skipped: the
+// input it tried is restored and nothing is added to the Submatch of the
+// returned Match. When a required element does not match, the whole sequence
+// fails to match and the input is restored. The Content of the returned Match
+// is the Content of each submatch, in order.
+func SeqOptional(
+	t token.Tag,
+	elems ...Elem,
+) parser.MatcherFunc {
 	return func(p *parser.Input) (*parser.Match, error) {
 		p = p.MayFail()
+		start := p.Offset()
+
+		content := make([]byte, 0)
+		ms := make([]*parser.Match, 0, len(elems))
+		for _, elem := range elems {
+			mtch := elem.Matcher
+			if elem.Optional {
+				mtch = TryAndKeep(mtch)
+			}
+
+			m, err := mtch.Match(p)
+			if err != nil {
+				p.Trace(parser.StageFail, "SeqOptional", t, err)
+				return nil, err
+			}
+
+			if m == nil {
+				if elem.Optional {
+					continue
+				}
+				return nil, nil
+			}
+
+			ms = append(ms, m)
+			content = append(content, m.Content...)
+		}
+
+		end := p.Offset()
+		p.Keep()
+
+		m := &parser.Match{
+			Tag:      t,
+			Content:  content,
+			Start:    start,
+			End:      end,
+			Submatch: ms,
+		}
+		p.Trace(parser.StageGot, "SeqOptional", t, m)
+		return m, nil
+	}
+}
+
+// NamedSeq is the Matcher returned by SeqNamed. Besides matching, it can report
+// the names of the groups it will produce.
+type NamedSeq struct {
+	t     token.Tag
+	names []string
+	mtchs []parser.Matcher
+}
+
+// SeqNamed returns a Matcher that applies each named Matcher in turn against
+// the input. Returns with no match immediately if any Matcher in the sequence
+// fails to match. Returns the whole Match if every Matcher succeeds. The
+// Matchers passed must all have a name passed in the arguments. (If you want a
+// submatch to be unnamed, pass the empty string.)
+//
+// The arguments are checked when SeqNamed is called. It panics if the number
+// of arguments is odd, if a name is not a string, or if a matcher does not
+// implement parser.Matcher. Either way, this happens before any input is read.
+func SeqNamed(
+	t token.Tag,
+	ms ...any,
+) *NamedSeq {
+	s := &NamedSeq{
+		t:     t,
+		names: make([]string, 0, len(ms)/2),
+		mtchs: make([]parser.Matcher, 0, len(ms)/2),
+	}
+
+	if len(ms)%2 != 0 {
+		panic(fmt.Sprintf(
+			"SeqNamed: expected name and matcher pairs, but got %d arguments",
+			len(ms)))
+	}
+
+	for i := 0; i < len(ms); i += 2 {
+		name, ok := ms[i].(string)
+		if !ok {
+			panic(fmt.Sprintf(
+				"SeqNamed: argument %d must be a string name, but got %T",
+				i, ms[i]))
+		}
+
+		mtch, ok := ms[i+1].(parser.Matcher)
+		if !ok {
+			panic(fmt.Sprintf(
+				"SeqNamed: argument %d must be a parser.Matcher, but got %T",
+				i+1, ms[i+1]))
+		}
+
+		s.names = append(s.names, name)
+		s.mtchs = append(s.mtchs, mtch)
+	}
+
+	return s
+}
+
+// Match applies each Matcher in the sequence to the input and returns a Match
+// built with parser.BuildMatch, so each named submatch is found in the Group
+// of the returned Match.
+func (s *NamedSeq) Match(p *parser.Input) (*parser.Match, error) {
+	pt := partialsFor(p)
+	saved := pt.start()
+	start := p.Offset()
 
+	ms := make([]*parser.Match, 0, len(s.mtchs))
+	mps := make([]any, 0, len(s.mtchs)*2)
+	for i, mtch := range s.mtchs {
+		pt.start()
+		at := p.Offset()
 		m, err := mtch.Match(p)
 		if err != nil {
 			return nil, err
 		}
 
 		if m == nil {
+			pt.fail(saved, s.t, s.names, ms, start, at)
 			return nil, nil
 		}
 
+		ms = append(ms, m)
+		mps = append(mps, s.names[i], m)
+	}
+
+	pt.done(saved)
+	m := parser.BuildMatch(s.t, mps...)
+	m.Start, m.End = start, p.Offset()
+	return m, nil
+}
+
+// GroupNames returns the names of the groups a successful Match will have, in
+// the order they were declared. Unnamed submatches are not included.
+func (s *NamedSeq) GroupNames() []string {
+	names := make([]string, 0, len(s.names))
+	for _, n := range s.names {
+		if n != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// Between returns a Matcher that matches open, then content, then close. The
+// returned Match has the given token.Tag and its Content is the full span, the
+// Content of all three matches including the delimiters. The Submatch will
+// contain all three matches in order and the middle Match is also found in the
+// Group named "body", so its Content is the text without the delimiters. If
+// any of the three fail to match, the whole Matcher fails to match and the
+// input is restored.
+func Between(
+	t token.Tag,
+	open, content, close parser.Matcher,
+) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		p = p.MayFail()
+		start := p.Offset()
+
+		p.Trace(parser.StageTry, "MatchBetween", t, open, content, close)
+
+		ms := make([]*parser.Match, 3)
+		for i, mtch := range []parser.Matcher{open, content, close} {
+			m, err := mtch.Match(p)
+			if err != nil {
+				p.Trace(parser.StageFail, "MatchBetween", t, open, content, close, err)
+				return nil, err
+			}
+
+			if m == nil {
+				return nil, nil
+			}
+
+			ms[i] = m
+		}
+
+		end := p.Offset()
 		p.Keep()
+
+		m := &parser.Match{
+			Tag:      t,
+			Content:  joinContent(ms),
+			Start:    start,
+			End:      end,
+			Group:    map[string]*parser.Match{"body": ms[1]},
+			Submatch: ms,
+		}
+
+		p.Trace(parser.StageGot, "MatchBetween", t, open, content, close, m)
+		return m, nil
+	}
+}
+
+// literal returns a Matcher that matches the bytes of bs against the next
+// bytes of input with a single comparison. It implements ByteSlice, RuneSlice,
+// and String.
+func literal(name string, t token.Tag, bs []byte) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		start := p.Offset()
+
+		ref, err := p.PeekRef(len(bs))
+		if !bytes.Equal(ref, bs[:len(ref)]) {
+			return nil, nil
+		}
+
+		if len(ref) < len(bs) {
+			if errors.Is(err, io.EOF) {
+				return nil, nil
+			}
+
+			p.Trace(parser.StageFail, name, t, bs, err)
+			return nil, err
+		}
+
+		c := p.MayFail()
+		content := make([]byte, len(bs))
+		if _, err := c.Read(content); err != nil {
+			p.Trace(parser.StageFail, name, t, bs, err)
+			return nil, err
+		}
+		c.Keep()
+
+		m := &parser.Match{
+			Tag:     t,
+			Content: content,
+			Start:   start,
+			End:     start + len(bs),
+		}
+		p.Trace(parser.StageGot, name, t, bs, m)
+		return m, nil
+	}
+}
+
+// ByteSlice returns a Matcher that returns Match when the given byte slice
+// matches the next bytes in the input. The Match has no submatches. If the
+// input does not match, nil is returned and the input is restored.
+func ByteSlice(
+	t token.Tag,
+	bs []byte,
+) parser.Matcher {
+	return literal("ByteSlice", t, append([]byte{}, bs...))
+}
+
+// RuneSlice returns a Matcher that returns Match when the given rune slice
+// matches the next runes in the input. The runes are compared in their UTF-8
+// encoding, so it works just like ByteSlice.
+func RuneSlice(
+	t token.Tag,
+	rs []rune,
+) parser.Matcher {
+	return literal("RuneSlice", t, []byte(string(rs)))
+}
+
+// String returns a Matcher that returns a Match when the given string matches
+// the next runes in the input. The string is compared in its UTF-8 encoding, so
+// it works just like ByteSlice.
+func String(
+	t token.Tag,
+	s string,
+) parser.Matcher {
+	return literal("String", t, []byte(s))
+}
+
+// trie is a prefix tree of the words matched by OneOfStrings.
+type trie struct {
+	next map[byte]*trie
+	word bool
+}
+
+// add adds the word to the trie.
+func (tr *trie) add(word string) {
+	for i := 0; i < len(word); i++ {
+		if tr.next == nil {
+			tr.next = map[byte]*trie{}
+		}
+
+		n := tr.next[word[i]]
+		if n == nil {
+			n = &trie{}
+			tr.next[word[i]] = n
+		}
+		tr = n
+	}
+	tr.word = true
+}
+
+// OneOfStrings returns a Matcher that matches any one of the given words. The
+// words are built into a prefix tree when the Matcher is created, so the input
+// is scanned just once however many words there are. When more than one word
+// matches, as "in" and "int" both do on the input "integer", the longest word
+// wins. Words are matched without regard for what follows, so to match "in"
+// only as a whole word, follow it with a Not. The Content of the returned Match
+// is the matched word. If no word matches, nil is returned.
+func OneOfStrings(t token.Tag, words ...string) parser.MatcherFunc {
+	root := &trie{}
+	for _, w := range words {
+		root.add(w)
+	}
+
+	return func(p *parser.Input) (*parser.Match, error) {
+		start := p.Offset()
+
+		longest := -1
+		if root.word {
+			longest = 0
+		}
+
+		for n, tr := 0, root; tr.next != nil; n++ {
+			bs, err := p.PeekRef(n + 1)
+			if len(bs) <= n {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+
+				p.Trace(parser.StageFail, "OneOfStrings", t, words, err)
+				return nil, err
+			}
+
+			tr = tr.next[bs[n]]
+			if tr == nil {
+				break
+			}
+
+			if tr.word {
+				longest = n + 1
+			}
+		}
+
+		if longest < 0 {
+			return nil, nil
+		}
+
+		c := p.MayFail()
+		content := make([]byte, longest)
+		if _, err := c.Read(content); err != nil {
+			p.Trace(parser.StageFail, "OneOfStrings", t, words, err)
+			return nil, err
+		}
+		c.Keep()
+
+		m := &parser.Match{
+			Tag:     t,
+			Content: content,
+			Start:   start,
+			End:     start + longest,
+		}
+		p.Trace(parser.StageGot, "OneOfStrings", t, words, m)
+		return m, nil
+	}
+}
+
+// equalFold reports whether the two runes are equal under Unicode simple case
+// folding.
+func equalFold(a, b rune) bool {
+	if a == b {
+		return true
+	}
+
+	for f := unicode.SimpleFold(a); f != a; f = unicode.SimpleFold(f) {
+		if f == b {
+			return true
+		}
+	}
+
+	return false
+}
+
+// StringFold returns a Matcher that returns a Match when the given string
+// matches the next runes in the input without regard to case. Runes are
+// compared using Unicode simple case folding, so exactly one rune of input is
+// matched for each rune of the string. Multi-rune folds are not supported: for
+// example, "ß" matches "ẞ", but not "SS". The Content of the returned Match is
+// the input that was matched, not the given string. If the input does not
+// match, nil is returned and the input is restored.
+func StringFold(
+	t token.Tag,
+	s string,
+) parser.Matcher {
+	return parser.MatcherFunc(func(p *parser.Input) (*parser.Match, error) {
+		p = p.MayFail()
+		start := p.Offset()
+
+		content := make([]byte, 0, len(s))
+		for _, r := range s {
+			var rs [1]rune
+			_, err := p.ReadRunes(rs[:])
+			if errors.Is(err, io.EOF) || errors.Is(err, parser.ErrIncompleteRune) {
+				return nil, nil
+			} else if err != nil {
+				p.Trace(parser.StageFail, "StringFold", t, s, err)
+				return nil, err
+			}
+
+			if !equalFold(r, rs[0]) {
+				return nil, nil
+			}
+
+			content = utf8.AppendRune(content, rs[0])
+		}
+
+		end := p.Offset()
+		p.Keep()
+
+		m := &parser.Match{Tag: t, Content: content, Start: start, End: end}
+		p.Trace(parser.StageGot, "StringFold", t, s, m)
+		return m, nil
+	})
+}
+
+// Optional returns a Matcher that returns the Match when the called Matcher
+// matches, but also returns an empty Match when the called Matcher does not
+// match. The token.Tag on the empty Match is token.None.
+func Optional(
+	mtch parser.Matcher,
+) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		m, err := TryAndKeep(mtch).Match(p)
+		if err != nil {
+			return nil, err
+		}
+
+		if m != nil {
+			return m, nil
+		}
+
+		at := p.Offset()
+		return &parser.Match{Tag: token.None, Start: at, End: at}, nil
+	}
+}
+
+// OptionalDefault works just like Optional, but when the called Matcher does
+// not match, the empty Match it returns has Made set to def. This is handy
+// when an omitted value has a default, such as a count that defaults to 1.
+func OptionalDefault(
+	mtch parser.Matcher,
+	def interface{},
+) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		m, err := TryAndKeep(mtch).Match(p)
+		if err != nil {
+			return nil, err
+		}
+
+		if m != nil {
+			return m, nil
+		}
+
+		at := p.Offset()
+		return &parser.Match{Tag: token.None, Made: def, Start: at, End: at}, nil
+	}
+}
+
+// TryAndKeep returns a matcher that will call the given Matcher and try to
+// match against the input. On fail, input is restored to what it was before. On
+// success, input moves forward to whatever the Matcher consumed.
+func TryAndKeep(mtch parser.Matcher) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		mark := p.Mark()
+		defer p.Release(mark)
+
+		return tryAt(p, mark, mtch)
+	}
+}
+
+// Not returns a Matcher that performs a negative lookahead. It tries the given
+// Matcher against the input. If that Matcher fails to match, Not returns an
+// empty Match with the token.None tag. If that Matcher matches, Not returns
+// nil. Either way, no input is consumed.
+func Not(mtch parser.Matcher) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		c := p.MayFail()
+
+		m, err := mtch.Match(c)
+		if err != nil {
+			return nil, err
+		}
+
+		if m != nil {
+			return nil, nil
+		}
+
+		at := p.Offset()
+		return &parser.Match{Tag: token.None, Start: at, End: at}, nil
+	}
+}
+
+// Peek returns a Matcher that performs a positive lookahead. It tries the given
+// Matcher against the input. If that Matcher fails, Peek returns nil. If that
+// Matcher matches, Peek returns an empty Match with the token.None tag whose
+// only Submatch is the peeked Match, so callers may inspect what was seen.
+// Either way, no input is consumed. As the returned Match has no Content, a
+// Peek inside of Seq does not add to the Content of the sequence.
+func Peek(mtch parser.Matcher) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		c := p.MayFail()
+
+		m, err := mtch.Match(c)
+		c.Discard()
+		if err != nil || m == nil {
+			return nil, err
+		}
+
+		at := p.Offset()
+		return &parser.Match{
+			Tag:      token.None,
+			Submatch: []*parser.Match{m},
+			Start:    at,
+			End:      at,
+		}, nil
+	}
+}
+
+// Skip returns a Matcher that consumes whatever the given Matcher matches, but
+// throws the Match away. On success, it returns an empty Match with the
+// token.None tag and no Content or Submatch, so a Skip inside of Seq consumes
+// its input without adding to the Content of the sequence. If the Matcher fails
+// to match, Skip returns nil and the input is restored.
+func Skip(mtch parser.Matcher) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		c := p.MayFail()
+		start := c.Offset()
+
+		m, err := mtch.Match(c)
+		if err != nil || m == nil {
+			return nil, err
+		}
+
+		end := c.Offset()
+		c.Keep()
+
+		return &parser.Match{
+			Tag:   token.None,
+			Start: start,
+			End:   end,
+		}, nil
+	}
+}
+
+// Label returns a Matcher that calls the given Matcher and returns its result.
+// If the Matcher fails to match, the failure is recorded on the input under
+// the given name (see parser.Input.RecordFailure). After the whole parse fails,
+// parser.Input.FurthestFailure reports the names of the labeled matchers that
+// failed furthest into the input.
+func Label(name string, mtch parser.Matcher) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		c := p.MayFail()
+
+		m, err := mtch.Match(c)
+		if err != nil {
+			return nil, err
+		}
+
+		if m == nil {
+			// record the position where the Matcher started
+			p.RecordFailure(name)
+			return nil, nil
+		}
+
+		c.Keep()
+		return m, nil
+	}
+}
+
+// Expect returns a Matcher that calls the given Matcher and returns its Match.
+// If the Matcher fails to match, Expect returns a *parser.ParseError at the
+// current position of the input, naming the given description as what was
+// expected. Use it where no other alternative could match, so that the parse
+// stops with a useful message rather than failing to match. The failure is
+// also recorded like a failure of Label.
+func Expect(name string, mtch parser.Matcher) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		c := p.MayFail()
+
+		m, err := mtch.Match(c)
+		if err != nil {
+			return nil, err
+		}
+
+		if m == nil {
+			// report the position where the Matcher started
+			p.RecordFailure(name)
+			err := parser.NewParseError(p, name)
+			p.Trace(parser.StageFail, "Expect", name, mtch, err)
+			return nil, err
+		}
+
+		c.Keep()
+		return m, nil
+	}
+}
+
+// EOF returns a Matcher that matches the end of input. If there is no more
+// input, it returns an empty Match with the token.None tag. Otherwise, it
+// returns nil. Either way, no input is consumed.
+func EOF() parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		c := p.MayFail()
+
+		var bs [1]byte
+		_, err := c.Read(bs[:])
+		if errors.Is(err, io.EOF) {
+			at := p.Offset()
+			return &parser.Match{Tag: token.None, Start: at, End: at}, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+}
+
+// Reparse returns a Matcher that first matches outer against the input. Then,
+// the Content of that Match is parsed again using inner, as a fresh input. The
+// inner Match is appended to the Submatch of the outer Match, which is
+// returned. If either fails to match, nil is returned and the input is
+// restored. If inner does not match all of the Content of the outer Match, an
+// error wrapping ErrTrailingInput is returned.
+//
+// This allows for layered parsing, where a coarse grammar splits the input into
+// fields and a detailed grammar parses the structure of a field.
+func Reparse(outer, inner parser.Matcher) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		c := p.MayFail()
+
+		m, err := outer.Match(c)
+		if err != nil || m == nil {
+			return nil, err
+		}
+
+		ip := parser.New(bytes.NewReader(m.Content))
+		ip.TraceFunc = p.TraceFunc
+		ip.TraceLevel = p.TraceLevel
+
+		im, err := inner.Match(ip)
+		if err != nil || im == nil {
+			return nil, err
+		}
+
+		end, err := EOF().Match(ip)
+		if err != nil {
+			return nil, err
+		}
+
+		if end == nil {
+			err := fmt.Errorf("%w: reparse matched %d of %d bytes",
+				ErrTrailingInput, consumed(im), m.Length())
+			p.Trace(parser.StageFail, "Reparse", outer, inner, err)
+			return nil, err
+		}
+
+		c.Keep()
+
+		sub := make([]*parser.Match, 0, len(m.Submatch)+1)
+		sub = append(sub, m.Submatch...)
+		m.Submatch = append(sub, im)
+
+		p.Trace(parser.StageGot, "Reparse", outer, inner, m)
+		return m, nil
+	}
+}
+
+// Map returns a Matcher that runs the given Matcher against the input. When it
+// matches, fn is called with the Match and the result is stored in the Made
+// field of the Match, which is then returned. Any error returned by fn is
+// returned as the error of the match.
+//
+// This is the usual way to turn a low-level match into a high-level object,
+// such as converting a run of digits into an int.
+func Map(
+	mtch parser.Matcher,
+	fn func(*parser.Match) (interface{}, error),
+) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		m, err := mtch.Match(p)
+		if err != nil || m == nil {
+			return nil, err
+		}
+
+		made, err := fn(m)
+		if err != nil {
+			p.Trace(parser.StageFail, "Map", mtch, err)
+			return nil, err
+		}
+
+		m.Made = made
 		return m, nil
 	}
 }
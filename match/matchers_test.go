@@ -1,8 +1,15 @@
 package match_test
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"strconv"
 	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/zostay/gordy/match"
 	"github.com/zostay/gordy/parser"
@@ -11,12 +18,12 @@ import (
 
 func Example() {
 	var (
-		TDotAtom      = token.NextTag()
-		TEmailAddress = token.NextTag()
-		TAreaCode     = token.NextTag()
-		TLocalCode    = token.NextTag()
-		TPersonalCode = token.NextTag()
-		TPhoneNumber  = token.NextTag()
+		TDotAtom      = token.Register("DotAtom")
+		TEmailAddress = token.Register("EmailAddress")
+		TAreaCode     = token.Register("AreaCode")
+		TLocalCode    = token.Register("LocalCode")
+		TPersonalCode = token.Register("PersonalCode")
+		TPhoneNumber  = token.Register("PhoneNumber")
 	)
 
 	var (
@@ -48,9 +55,9 @@ func Example() {
 		MatchDomain    = MatchDotAtom
 
 		MatchEmailAddress = match.SeqNamed(TEmailAddress,
-			MatchLocalPart,
-			match.OneByte(token.Literal, match.BytesInSet('@')),
-			MatchDomain,
+			"local", MatchLocalPart,
+			"", match.OneByte(token.Literal, match.BytesInSet('@')),
+			"domain", MatchDomain,
 		)
 
 		MatchAreaCode     = match.NBytes(TAreaCode, 3, 3, digits)
@@ -81,4 +88,1631 @@ func Example() {
 	}
 
 	fmt.Println(m)
+
+	// Output:
+	// PhoneNumber "555-555-5555"
+	//   AreaCode "555"
+	//   Literal "-"
+	//   LocalCode "555"
+	//   Literal "-"
+	//   PersonalCode "5555"
+}
+
+// contactTags holds the tags of the grammar built by contactInfo.
+type contactTags struct {
+	DotAtom      token.Tag
+	EmailAddress token.Tag
+	AreaCode     token.Tag
+	LocalCode    token.Tag
+	PersonalCode token.Tag
+	PhoneNumber  token.Tag
+}
+
+// contactInfo builds the grammar of the Example, a phone number or an email
+// address, for the tests and benchmarks that need it.
+func contactInfo() (parser.Matcher, contactTags) {
+	tags := contactTags{
+		DotAtom:      token.NextTag(),
+		EmailAddress: token.NextTag(),
+		AreaCode:     token.NextTag(),
+		LocalCode:    token.NextTag(),
+		PersonalCode: token.NextTag(),
+		PhoneNumber:  token.NextTag(),
+	}
+
+	digits := match.BytesInRange('0', '9')
+	hyphen := match.OneByte(token.Literal, match.BytesInSet('-'))
+
+	atext := match.First(
+		match.OneByte(token.Literal,
+			match.BytesInRange('a', 'z'),
+			match.BytesInRange('A', 'Z'),
+		),
+		match.OneByte(token.Literal, digits),
+		match.OneByte(token.Literal,
+			match.BytesInSet(
+				'!', '#', '$', '%', '&', '\'', '*', '+', '-', '/',
+				'=', '?', '^', '_', '`', '{', '|', '}', '~',
+			),
+		),
+	)
+
+	dotAtom := match.ManyWithSep(tags.DotAtom, 1,
+		match.Many(token.Literal, 1, atext),
+		match.OneByte(token.Literal, match.BytesInSet('.')),
+	)
+
+	email := match.SeqNamed(tags.EmailAddress,
+		"local", dotAtom,
+		"", match.OneByte(token.Literal, match.BytesInSet('@')),
+		"domain", dotAtom,
+	)
+
+	phone := match.Seq(
+		tags.PhoneNumber,
+		match.NBytes(tags.AreaCode, 3, 3, digits),
+		match.Optional(hyphen),
+		match.NBytes(tags.LocalCode, 3, 3, digits),
+		match.Optional(hyphen),
+		match.NBytes(tags.PersonalCode, 4, 4, digits),
+	)
+
+	return match.Longest(phone, email), tags
+}
+
+func BenchmarkContactInfo(b *testing.B) {
+	grammar, _ := contactInfo()
+	contacts := []string{
+		"555-555-5555",
+		"5555555555",
+		"john.q.public@example.com",
+		"555@example.com",
+		"555-555-555x",
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, contact := range contacts {
+			p := parser.New(strings.NewReader(contact))
+			if _, err := grammar.Match(p); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// byteIn is a minimal matcher for a single byte in the given set. It treats
+// the end of input as a failure to match.
+func byteIn(cs ...byte) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		var bs [1]byte
+		_, err := p.Read(bs[:])
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		for _, c := range cs {
+			if c == bs[0] {
+				return &parser.Match{Tag: token.Literal, Content: bs[:]}, nil
+			}
+		}
+
+		return nil, nil
+	}
+}
+
+func TestBetween(t *testing.T) {
+	t.Parallel()
+
+	TGroup := token.NextTag()
+
+	letter := byteIn([]byte("abcdefghijklmnopqrstuvwxyz")...)
+
+	var group parser.Matcher
+	nested := parser.MatcherFunc(func(p *parser.Input) (*parser.Match, error) {
+		return group.Match(p)
+	})
+
+	group = match.Between(TGroup,
+		byteIn('('),
+		match.Many(token.Literal, 0, match.First(letter, nested)),
+		byteIn(')'),
+	)
+
+	p := parser.New(strings.NewReader("(a(bc)d)"))
+	m, err := group.Match(p)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+
+	assert.Equal(t, TGroup, m.Tag)
+	assert.Equal(t, "(a(bc)d)", string(m.Content))
+	require.Len(t, m.Submatch, 3)
+	assert.Equal(t, "(", string(m.Submatch[0].Content))
+	assert.Equal(t, ")", string(m.Submatch[2].Content))
+
+	body := m.Submatch[1]
+	require.Len(t, body.Submatch, 3)
+	assert.Equal(t, "a", string(body.Submatch[0].Content))
+	assert.Equal(t, TGroup, body.Submatch[1].Tag)
+	assert.Equal(t, "(bc)", string(body.Submatch[1].Content))
+	assert.Equal(t, "bc", string(body.Submatch[1].Group["body"].Content))
+	assert.Equal(t, "d", string(body.Submatch[2].Content))
+}
+
+func TestBetween_Unclosed(t *testing.T) {
+	t.Parallel()
+
+	letter := byteIn([]byte("abcdefghijklmnopqrstuvwxyz")...)
+	group := match.Between(token.Literal,
+		byteIn('('),
+		match.Many(token.Literal, 0, match.First(letter)),
+		byteIn(')'),
+	)
+
+	p := parser.New(strings.NewReader("(ab"))
+	m, err := group.Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	// input is restored, so the open bracket is still there
+	m, err = byteIn('(').Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "(", string(m.Content))
+}
+
+func TestNamedSeq_GroupNames(t *testing.T) {
+	t.Parallel()
+
+	at := byteIn('@')
+	word := match.Many(token.Literal, 1, match.TryAndKeep(byteIn('a', 'b', 'c')))
+	email := match.SeqNamed(token.Literal,
+		"local", word,
+		"", at,
+		"domain", word,
+	)
+
+	assert.Equal(t, []string{"local", "domain"}, email.GroupNames())
+
+	p := parser.New(strings.NewReader("ab@cc"))
+	m, err := email.Match(p)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+
+	for _, name := range email.GroupNames() {
+		assert.Contains(t, m.Group, name)
+	}
+	assert.Len(t, m.Group, len(email.GroupNames()))
+}
+
+func TestFirst_Restores(t *testing.T) {
+	t.Parallel()
+
+	// reads and sets a value, then fails
+	spoiler := parser.MatcherFunc(func(p *parser.Input) (*parser.Match, error) {
+		_, _ = p.ReadByte()
+		p.SetValue("spoiled", true)
+		return nil, nil
+	})
+
+	ab := match.Seq(token.Literal, byteIn('a'), byteIn('b'))
+
+	p := parser.New(strings.NewReader("ab"))
+	m, err := match.First(spoiler, ab).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "ab", m.Text())
+	assert.Nil(t, p.Value("spoiled"))
+
+	p = parser.New(strings.NewReader("ab"))
+	m, err = match.TryAndKeep(spoiler).Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+	assert.Equal(t, 0, p.Offset())
+	assert.Nil(t, p.Value("spoiled"))
+}
+
+func BenchmarkFirst(b *testing.B) {
+	word := match.Many(token.Literal, 1, match.First(
+		match.TryAndKeep(match.Seq(token.Literal, byteIn('a'), byteIn('z'))),
+		match.TryAndKeep(match.Seq(token.Literal, byteIn('a'), byteIn('b'))),
+		byteIn('c'),
+	))
+	in := strings.Repeat("abc", 100)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := parser.New(strings.NewReader(in))
+		if _, err := word.Match(p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestManyFunc(t *testing.T) {
+	t.Parallel()
+
+	const rows = 100000
+	in := strings.Repeat("row\n", rows)
+	row := match.Seq(token.Literal,
+		match.TakeWhile1(token.Literal, match.BytesInRange('a', 'z')),
+		byteIn('\n'),
+	)
+
+	n := 0
+	yield := func(m *parser.Match) error {
+		assert.Equal(t, "row\n", m.Text())
+		assert.Equal(t, 4*n, m.Start)
+		n++
+		return nil
+	}
+
+	// the buffer is far smaller than the input, so it must be discarded as
+	// the rows are matched
+	p := parser.NewSize(strings.NewReader(in), 64)
+	m, err := match.ManyFunc(token.Literal, 1, row, yield).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, rows, n)
+	assert.Equal(t, rows, m.Made)
+	assert.Equal(t, 0, m.Start)
+	assert.Equal(t, len(in), m.End)
+	assert.Empty(t, m.Content)
+	assert.Empty(t, m.Submatch)
+
+	// too few matches
+	n = 0
+	p = parser.New(strings.NewReader("row\nrow\n"))
+	m, err = match.ManyFunc(token.Literal, 3, row, yield).Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+	assert.Equal(t, 2, n)
+
+	// yield stops the match with an error
+	errStop := errors.New("stop")
+	seen := 0
+	p = parser.New(strings.NewReader(in))
+	m, err = match.ManyFunc(token.Literal, 0, row, func(*parser.Match) error {
+		seen++
+		if seen == 3 {
+			return errStop
+		}
+		return nil
+	}).Match(p)
+	assert.ErrorIs(t, err, errStop)
+	assert.Nil(t, m)
+	assert.Equal(t, 3, seen)
+}
+
+func BenchmarkMany(b *testing.B) {
+	in := strings.Repeat("a", 3000)
+	comma := byteIn(',')
+	sepIn := strings.TrimSuffix(strings.Repeat("a,", 1500), ",")
+
+	benchmarks := []struct {
+		name string
+		mtch parser.Matcher
+		in   string
+	}{
+		{"Many", match.Many(token.Literal, 0, byteIn('a')), in},
+		{"ManySafe", match.ManySafe(token.Literal, 0, byteIn('a')), in},
+		{"ManyWithSep", match.ManyWithSep(token.Literal, 0, byteIn('a'), comma), sepIn},
+		{"None", match.Many(token.Literal, 0, byteIn('b')), in},
+	}
+
+	for _, bm := range benchmarks {
+		bm := bm
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				p := parser.New(strings.NewReader(bm.in))
+				if _, err := bm.mtch.Match(p); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestLongest_AllFail(t *testing.T) {
+	t.Parallel()
+
+	longest := match.Longest(byteIn('a'), byteIn('b'))
+
+	p := parser.New(strings.NewReader("cab"))
+	m, err := longest.Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	// input position is untouched
+	m, err = byteIn('c').Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "c", string(m.Content))
+
+	// the same goes for the other variants
+	always := func(a, b *parser.Match) bool { return true }
+	for _, longest := range []parser.Matcher{
+		match.LongestBy(always, byteIn('a'), byteIn('b')),
+		match.LongestDebug(true, byteIn('a'), byteIn('b')),
+	} {
+		p := parser.New(strings.NewReader("cab"))
+		m, err := longest.Match(p)
+		assert.NoError(t, err)
+		assert.Nil(t, m)
+		assert.Equal(t, 0, p.Offset())
+	}
+}
+
+var digit = byteIn('0', '1', '2', '3', '4', '5', '6', '7', '8', '9')
+
+func ExampleCount() {
+	digit := match.OneByte(token.Literal, match.BytesInRange('0', '9'))
+	fourDigits := match.Count(token.Literal, 4, digit)
+
+	p := parser.New(strings.NewReader("12345"))
+	m, _ := fourDigits.Match(p)
+	fmt.Println(string(m.Content), len(m.Submatch))
+
+	p = parser.New(strings.NewReader("123"))
+	m, _ = fourDigits.Match(p)
+	fmt.Println(m == nil)
+
+	// Output:
+	// 1234 4
+	// true
+}
+
+func TestCount(t *testing.T) {
+	t.Parallel()
+
+	count := match.Count(token.Literal, 4, digit)
+
+	p := parser.New(strings.NewReader("12345"))
+	m, err := count.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "1234", string(m.Content))
+	assert.Len(t, m.Submatch, 4)
+
+	m, err = digit.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "5", string(m.Content))
+
+	p = parser.New(strings.NewReader("123"))
+	m, err = count.Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	m, err = digit.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "1", string(m.Content))
+}
+
+func TestRepeat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		min, max int
+		input    string
+		want     string
+		rest     string
+		fail     bool
+	}{
+		{"stops at max", 2, 5, "1234567", "12345", "6", false},
+		{"between", 2, 5, "123a", "123", "a", false},
+		{"at min", 2, 5, "12a", "12", "a", false},
+		{"below min", 2, 5, "1a", "", "1", true},
+		{"max is zero", 0, 0, "123", "", "1", false},
+		{"min is max", 3, 3, "12345", "123", "4", false},
+		{"input ends before min", 3, 5, "12", "", "1", true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := match.Repeat(token.Literal, tt.min, tt.max, digit).Match(p)
+			assert.NoError(t, err)
+			if tt.fail {
+				assert.Nil(t, m)
+			} else {
+				require.NotNil(t, m)
+				assert.Equal(t, tt.want, string(m.Content))
+			}
+
+			var bs [1]byte
+			_, err = p.Read(bs[:])
+			assert.NoError(t, err)
+			assert.Equal(t, tt.rest, string(bs[:]))
+		})
+	}
+}
+
+func TestNot(t *testing.T) {
+	t.Parallel()
+
+	a := byteIn('a')
+	b := byteIn('b')
+	abc := byteIn('a', 'b', 'c')
+	aNotB := match.Seq(token.Literal, a, match.Not(b), abc)
+
+	p := parser.New(strings.NewReader("ac"))
+	m, err := aNotB.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	require.Len(t, m.Submatch, 3)
+	assert.Equal(t, token.None, m.Submatch[1].Tag)
+	assert.Equal(t, 0, m.Submatch[1].Length())
+	assert.Equal(t, "c", string(m.Submatch[2].Content))
+
+	p = parser.New(strings.NewReader("ab"))
+	m, err = aNotB.Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestNot_ConsumesNothing(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("ab"))
+	m, err := match.Not(byteIn('b')).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+
+	m, err = match.Not(byteIn('a')).Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	m, err = byteIn('a').Match(p)
+	assert.NoError(t, err)
+	assert.NotNil(t, m)
+}
+
+func TestPeek(t *testing.T) {
+	t.Parallel()
+
+	ab := match.Seq(token.Literal, byteIn('a'), byteIn('b'))
+
+	p := parser.New(strings.NewReader("abc"))
+	m, err := match.Peek(ab).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, token.None, m.Tag)
+	assert.Equal(t, 0, m.Length())
+	require.Len(t, m.Submatch, 1)
+	assert.Equal(t, "ab", string(m.Submatch[0].Content))
+
+	// the cursor has not moved
+	var bs [3]byte
+	n, err := p.Read(bs[:])
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", string(bs[:n]))
+}
+
+func TestPeek_Fail(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("ax"))
+	m, err := match.Peek(match.Seq(token.Literal, byteIn('a'), byteIn('b'))).Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	var bs [2]byte
+	n, err := p.Read(bs[:])
+	assert.NoError(t, err)
+	assert.Equal(t, "ax", string(bs[:n]))
+}
+
+func TestPeek_InSeq(t *testing.T) {
+	t.Parallel()
+
+	// a digit, but only when followed by a letter
+	digitThenLetter := match.Seq(token.Literal,
+		digit,
+		match.Peek(byteIn('a', 'b', 'c')),
+	)
+
+	p := parser.New(strings.NewReader("1a"))
+	m, err := digitThenLetter.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+
+	// the lookahead adds nothing to the content of the sequence
+	assert.Equal(t, "1", string(m.Content))
+	require.Len(t, m.Submatch, 2)
+	assert.Equal(t, token.None, m.Submatch[1].Tag)
+	assert.Empty(t, m.Submatch[1].Content)
+
+	m, err = byteIn('a').Match(p)
+	assert.NoError(t, err)
+	assert.NotNil(t, m)
+
+	p = parser.New(strings.NewReader("12"))
+	m, err = digitThenLetter.Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestSkip(t *testing.T) {
+	t.Parallel()
+
+	spaces := match.TakeWhile1(token.Literal, match.BytesInSet(' '))
+	pair := match.Seq(token.Literal,
+		digit,
+		match.Skip(spaces),
+		match.Skip(byteIn(',')),
+		match.Optional(match.Skip(spaces)),
+		digit,
+	)
+
+	p := parser.New(strings.NewReader("1  , 2;"))
+	m, err := pair.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+
+	// the skipped text is consumed, but is not in the content
+	assert.Equal(t, "12", string(m.Content))
+	assert.Equal(t, 0, m.Start)
+	assert.Equal(t, 6, m.End)
+	for _, sm := range m.Submatch[1:4] {
+		assert.Equal(t, token.None, sm.Tag)
+		assert.Empty(t, sm.Content)
+		assert.Empty(t, sm.Submatch)
+	}
+
+	// on failure, nothing is consumed
+	p = parser.New(strings.NewReader("x"))
+	m, err = match.Skip(spaces).Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	m, err = byteIn('x').Match(p)
+	assert.NoError(t, err)
+	assert.NotNil(t, m)
+}
+
+func TestOptionalDefault(t *testing.T) {
+	t.Parallel()
+
+	// an optional repeat count that defaults to 1
+	count := match.OptionalDefault(match.Integer(token.Literal, 10), int64(1))
+	item := match.Seq(token.Literal, count, byteIn('x'))
+
+	p := parser.New(strings.NewReader("3x"))
+	m, err := item.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, token.Literal, m.Submatch[0].Tag)
+	assert.Equal(t, int64(3), m.Submatch[0].Made)
+	assert.Equal(t, "3x", string(m.Content))
+
+	p = parser.New(strings.NewReader("x"))
+	m, err = item.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, token.None, m.Submatch[0].Tag)
+	assert.Empty(t, m.Submatch[0].Content)
+	assert.Equal(t, int64(1), m.Submatch[0].Made)
+	assert.Equal(t, "x", string(m.Content))
+}
+
+func TestSeq_Content(t *testing.T) {
+	t.Parallel()
+
+	seq := match.Seq(token.Literal,
+		match.NBytes(token.Literal, 1, 3, match.BytesInRange('0', '9')),
+		byteIn('-'),
+		match.NBytes(token.Literal, 1, 3, match.BytesInRange('a', 'z')),
+	)
+
+	p := parser.New(strings.NewReader("123-abc!"))
+	m, err := seq.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "123-abc", string(m.Content))
+	assert.Equal(t, 7, m.Length())
+}
+
+func TestSeq_ContentStrings(t *testing.T) {
+	t.Parallel()
+
+	seq := match.Seq(token.Literal,
+		match.String(token.Literal, "ab"),
+		match.String(token.Literal, "cd"),
+	)
+
+	p := parser.New(strings.NewReader("abcd"))
+	m, err := seq.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "abcd", string(m.Content))
+	assert.Len(t, m.Submatch, 2)
+}
+
+func TestEOF(t *testing.T) {
+	t.Parallel()
+
+	number := match.Seq(token.Literal,
+		match.NBytes(token.Literal, 1, 10, match.BytesInRange('0', '9')),
+		match.EOF(),
+	)
+
+	p := parser.New(strings.NewReader("123"))
+	m, err := number.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "123", string(m.Content))
+
+	p = parser.New(strings.NewReader("123abc"))
+	m, err = number.Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestEOF_ConsumesNothing(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("a"))
+	m, err := match.EOF().Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	m, err = byteIn('a').Match(p)
+	assert.NoError(t, err)
+	assert.NotNil(t, m)
+
+	m, err = match.EOF().Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, token.None, m.Tag)
+	assert.Equal(t, 0, m.Length())
+}
+
+func TestNot_ClosingTag(t *testing.T) {
+	t.Parallel()
+
+	// any character that is not the start of a closing tag
+	text := match.Many(token.Literal, 1,
+		match.Seq(token.Literal,
+			match.Not(match.String(token.Literal, "</")),
+			match.OneByte(token.Literal, match.NotBytes()),
+		),
+	)
+
+	p := parser.New(strings.NewReader("a<b</c>"))
+	m, err := text.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "a<b", string(m.Content))
+
+	m, err = match.String(token.Literal, "</c>").Match(p)
+	assert.NoError(t, err)
+	assert.NotNil(t, m)
+}
+
+func TestBetween_Body(t *testing.T) {
+	t.Parallel()
+
+	digits := match.NBytes(token.Literal, 1, 10, match.BytesInRange('0', '9'))
+	parens := match.Between(token.Literal, byteIn('('), digits, byteIn(')'))
+
+	p := parser.New(strings.NewReader("(123)"))
+	m, err := parens.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "(123)", string(m.Content))
+	assert.Equal(t, 0, m.Start)
+	assert.Equal(t, 5, m.End)
+	require.Contains(t, m.Group, "body")
+	assert.Equal(t, "123", string(m.Group["body"].Content))
+	assert.Same(t, m.Submatch[1], m.Group["body"])
+
+	p = parser.New(strings.NewReader("(123"))
+	m, err = parens.Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	m, err = byteIn('(').Match(p)
+	assert.NoError(t, err)
+	assert.NotNil(t, m)
+}
+
+func TestLiterals(t *testing.T) {
+	t.Parallel()
+
+	long := strings.Repeat("literal ", 500)
+
+	tests := []struct {
+		name  string
+		mtch  parser.Matcher
+		input string
+		want  string
+		ok    bool
+	}{
+		{"ByteSlice", match.ByteSlice(token.Literal, []byte("func")), "func()", "func", true},
+		{"ByteSlice mismatch", match.ByteSlice(token.Literal, []byte("func")), "fund", "", false},
+		{"ByteSlice partial at EOF", match.ByteSlice(token.Literal, []byte("func")), "fun", "", false},
+		{"ByteSlice empty", match.ByteSlice(token.Literal, []byte{}), "x", "", true},
+		{"RuneSlice", match.RuneSlice(token.Literal, []rune("λx")), "λx.x", "λx", true},
+		{"RuneSlice partial at EOF", match.RuneSlice(token.Literal, []rune("λx")), "λ", "", false},
+		{"String", match.String(token.Literal, "→"), "→ b", "→", true},
+		{"String mismatch", match.String(token.Literal, "→"), "←", "", false},
+		{"String at EOF", match.String(token.Literal, "→"), "", "", false},
+		{"String long", match.String(token.Literal, long), long + "!", long, true},
+		{"String long mismatch at end", match.String(token.Literal, long+"?"), long + "!", "", false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := tt.mtch.Match(p)
+			assert.NoError(t, err)
+
+			if !tt.ok {
+				assert.Nil(t, m)
+
+				// the input is restored
+				assert.Equal(t, 0, p.Offset())
+				return
+			}
+
+			require.NotNil(t, m)
+			assert.Equal(t, tt.want, string(m.Content))
+			assert.Empty(t, m.Submatch)
+			assert.Equal(t, len(tt.want), p.Offset())
+		})
+	}
+}
+
+func BenchmarkByteSlice(b *testing.B) {
+	keyword := []byte("implementation_defined_behavior")
+	in := string(keyword) + "!"
+
+	seq := make([]parser.Matcher, len(keyword))
+	for i, c := range keyword {
+		seq[i] = match.OneByte(token.Literal, match.BytesInSet(c))
+	}
+
+	for _, bc := range []struct {
+		name string
+		mtch parser.Matcher
+	}{
+		{"SeqOfOneByte", match.Seq(token.Literal, seq...)},
+		{"ByteSlice", match.ByteSlice(token.Literal, keyword)},
+		{"String", match.String(token.Literal, string(keyword))},
+	} {
+		bc := bc
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				p := parser.New(strings.NewReader(in))
+				if m, err := bc.mtch.Match(p); err != nil || m == nil {
+					b.Fatal("failed to match", err)
+				}
+			}
+		})
+	}
+}
+
+func TestOneOfStrings(t *testing.T) {
+	t.Parallel()
+
+	keywords := match.OneOfStrings(token.Literal, "in", "int", "interface", "if")
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"interface{}", "interface"},
+		{"integer", "int"},
+		{"inter", "int"},
+		{"int", "int"},
+		{"in x", "in"},
+		{"if", "if"},
+		{"i", ""},
+		{"for", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := keywords.Match(p)
+			assert.NoError(t, err)
+
+			if tt.want == "" {
+				assert.Nil(t, m)
+				assert.Equal(t, 0, p.Offset())
+				return
+			}
+
+			require.NotNil(t, m)
+			assert.Equal(t, tt.want, string(m.Content))
+			assert.Equal(t, len(tt.want), p.Offset())
+		})
+	}
+}
+
+func BenchmarkOneOfStrings(b *testing.B) {
+	words := []string{
+		"break", "case", "chan", "const", "continue", "default", "defer",
+		"else", "fallthrough", "for", "func", "go", "goto", "if", "import",
+		"interface", "map", "package", "range", "return", "select",
+		"struct", "switch", "type", "var",
+	}
+
+	strs := make([]parser.Matcher, len(words))
+	for i, w := range words {
+		strs[i] = match.String(token.Literal, w)
+	}
+
+	inputs := []string{"var", "func", "interface", "fallthrough", "x"}
+
+	for _, bc := range []struct {
+		name string
+		mtch parser.Matcher
+	}{
+		{"FirstOfString", match.First(strs...)},
+		{"OneOfStrings", match.OneOfStrings(token.Literal, words...)},
+	} {
+		bc := bc
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, in := range inputs {
+					p := parser.New(strings.NewReader(in))
+					if _, err := bc.mtch.Match(p); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestStringFold(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern string
+		input   string
+		want    string
+		fail    bool
+	}{
+		{"GET", "GET /", "GET", false},
+		{"GET", "get /", "get", false},
+		{"GET", "gEt /", "gEt", false},
+		{"GET", "GOT /", "", true},
+		{"GET", "GE", "", true},
+		{"kelvin", "KELVIN", "KELVIN", false},
+		{"ß", "ẞ", "ẞ", false},
+		{"straße", "STRAẞE", "STRAẞE", false},
+		{"straße", "STRASSE", "", true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.pattern+"/"+tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := match.StringFold(token.Literal, tt.pattern).Match(p)
+			assert.NoError(t, err)
+			if tt.fail {
+				assert.Nil(t, m)
+				return
+			}
+
+			require.NotNil(t, m)
+			assert.Equal(t, tt.want, string(m.Content))
+		})
+	}
+}
+
+func TestLongestDebug(t *testing.T) {
+	t.Parallel()
+
+	alts := []parser.Matcher{
+		match.NBytes(token.Literal, 1, 2, match.BytesInRange('0', '9')),
+		byteIn('x'),
+		match.NBytes(token.Literal, 1, 5, match.BytesInRange('0', '9')),
+	}
+
+	p := parser.New(strings.NewReader("1234"))
+	m, err := match.LongestDebug(true, alts...).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "1234", string(m.Content))
+	assert.Equal(t, map[int]int{0: 2, 1: -1, 2: 4}, m.Made)
+	require.Len(t, m.Submatch, 1)
+	assert.Equal(t, "1234", string(m.Submatch[0].Content))
+
+	made := match.Map(alts[2], func(m *parser.Match) (interface{}, error) {
+		return "digits", nil
+	})
+	p = parser.New(strings.NewReader("1234"))
+	m, err = match.LongestDebug(true, alts[0], made).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, map[int]int{0: 2, 1: 4}, m.Made)
+	require.Len(t, m.Submatch, 1)
+	assert.Equal(t, "digits", m.Submatch[0].Made)
+
+	p = parser.New(strings.NewReader("1234"))
+	m, err = match.LongestDebug(false, alts...).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "1234", string(m.Content))
+	assert.Nil(t, m.Made)
+}
+
+func TestLongest_Skip(t *testing.T) {
+	t.Parallel()
+
+	// the first alternative consumes more input with less content
+	skipped := match.Seq(token.Literal,
+		match.Skip(match.String(token.Literal, "   ")),
+		match.String(token.Literal, "ab"),
+	)
+	literal := match.String(token.Literal, "   a")
+
+	p := parser.New(strings.NewReader("   ab"))
+	m, err := match.Longest(literal, skipped).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "ab", string(m.Content))
+	assert.Equal(t, 5, m.End)
+	assert.True(t, p.AtEOF())
+
+	p = parser.New(strings.NewReader("   ab"))
+	m, err = match.LongestDebug(true, literal, skipped).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, map[int]int{0: 4, 1: 5}, m.Made)
+}
+
+func TestLongest_Ties(t *testing.T) {
+	t.Parallel()
+
+	TA := token.NextTag()
+	TB := token.NextTag()
+	TC := token.NextTag()
+	TShort := token.NextTag()
+
+	ab := match.String(TA, "ab")
+	anyTwo := match.NBytes(TB, 2, 2, match.BytesInRange('a', 'z'))
+	lower := match.NBytes(TC, 1, 2, match.BytesInRange('a', 'z'))
+	a := match.String(TShort, "a")
+
+	tests := []struct {
+		name    string
+		longest func(...parser.Matcher) parser.MatcherFunc
+		ms      []parser.Matcher
+		want    token.Tag
+	}{
+		{"first", match.Longest, []parser.Matcher{ab, anyTwo}, TA},
+		{"first reversed", match.Longest, []parser.Matcher{anyTwo, ab}, TB},
+		{"first of all", match.Longest, []parser.Matcher{a, ab, anyTwo, lower}, TA},
+		{"last", match.LongestPreferLast, []parser.Matcher{ab, anyTwo}, TB},
+		{"last reversed", match.LongestPreferLast, []parser.Matcher{anyTwo, ab}, TA},
+		{"last of all", match.LongestPreferLast, []parser.Matcher{ab, anyTwo, lower, a}, TC},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader("abc"))
+			m, err := tt.longest(tt.ms...).Match(p)
+			assert.NoError(t, err)
+			require.NotNil(t, m)
+			assert.Equal(t, tt.want, m.Tag)
+			assert.Equal(t, "ab", m.Text())
+			assert.Equal(t, 2, p.Offset())
+		})
+	}
+}
+
+func TestLongestBy(t *testing.T) {
+	t.Parallel()
+
+	TIdent := token.NextTag()
+	TKeyword := token.NextTag()
+
+	// "if" is both an identifier and a keyword, matched at the same length
+	ident := match.NBytes(TIdent, 1, 10, match.BytesInRange('a', 'z'))
+	keyword := match.String(TKeyword, "if")
+
+	// first wins on a tie
+	p := parser.New(strings.NewReader("if"))
+	m, err := match.Longest(ident, keyword).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, TIdent, m.Tag)
+
+	p = parser.New(strings.NewReader("if"))
+	m, err = match.Longest(keyword, ident).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, TKeyword, m.Tag)
+
+	// prefer keywords regardless of order
+	preferKeyword := func(a, b *parser.Match) bool {
+		return a.Tag == TKeyword
+	}
+
+	p = parser.New(strings.NewReader("if"))
+	m, err = match.LongestBy(preferKeyword, ident, keyword).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, TKeyword, m.Tag)
+
+	// but longer still wins
+	p = parser.New(strings.NewReader("iffy"))
+	m, err = match.LongestBy(preferKeyword, ident, keyword).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, TIdent, m.Tag)
+	assert.Equal(t, "iffy", string(m.Content))
+}
+
+func TestReparse(t *testing.T) {
+	t.Parallel()
+
+	TField := token.NextTag()
+	TPair := token.NextTag()
+
+	// a field is everything up to a semicolon
+	field := match.NBytes(TField, 1, 100, match.NotBytes(match.BytesInSet(';')))
+	letters := match.NBytes(token.Literal, 1, 10, match.BytesInRange('a', 'z'))
+	pair := match.SeqNamed(TPair,
+		"key", letters,
+		"", byteIn('='),
+		"value", letters,
+	)
+
+	p := parser.New(strings.NewReader("abc=def;"))
+	m, err := match.Reparse(field, pair).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, TField, m.Tag)
+	assert.Equal(t, "abc=def", string(m.Content))
+	require.Len(t, m.Submatch, 1)
+	assert.Equal(t, TPair, m.Submatch[0].Tag)
+	assert.Equal(t, "def", string(m.Submatch[0].Group["value"].Content))
+
+	m, err = byteIn(';').Match(p)
+	assert.NoError(t, err)
+	assert.NotNil(t, m)
+
+	p = parser.New(strings.NewReader("abc=de1;"))
+	m, err = match.Reparse(field, pair).Match(p)
+	assert.ErrorIs(t, err, match.ErrTrailingInput)
+	assert.Nil(t, m)
+
+	p = parser.New(strings.NewReader("abc;"))
+	m, err = match.Reparse(field, pair).Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	m, err = byteIn('a').Match(p)
+	assert.NoError(t, err)
+	assert.NotNil(t, m)
+}
+
+func TestSeparated(t *testing.T) {
+	t.Parallel()
+
+	letter := byteIn('a', 'b', 'c')
+	comma := byteIn(',')
+
+	matchers := map[string]parser.Matcher{
+		"ManyWithSep": match.ManyWithSep(token.Literal, 1, letter, comma),
+		"SepEndBy":    match.SepEndBy(token.Literal, 1, letter, comma),
+		"EndBy":       match.EndBy(token.Literal, 1, letter, comma),
+	}
+
+	type result struct {
+		content string
+		count   int
+		rest    string
+	}
+
+	tests := []struct {
+		input string
+		want  map[string]result
+	}{
+		{"a,b,c", map[string]result{
+			"ManyWithSep": {"a,b,c", 3, ""},
+			"SepEndBy":    {"a,b,c", 3, ""},
+			"EndBy":       {"a,b,", 2, "c"},
+		}},
+		{"a,b,c,", map[string]result{
+			"ManyWithSep": {"a,b,c", 3, ","},
+			"SepEndBy":    {"a,b,c,", 3, ""},
+			"EndBy":       {"a,b,c,", 3, ""},
+		}},
+		{"a,,b", map[string]result{
+			"ManyWithSep": {"a", 1, ","},
+			"SepEndBy":    {"a,", 1, ","},
+			"EndBy":       {"a,", 1, ","},
+		}},
+	}
+
+	for _, tt := range tests {
+		for name, mtch := range matchers {
+			tt, name, mtch := tt, name, mtch
+			t.Run(name+"/"+tt.input, func(t *testing.T) {
+				t.Parallel()
+
+				want := tt.want[name]
+
+				p := parser.New(strings.NewReader(tt.input))
+				m, err := mtch.Match(p)
+				assert.NoError(t, err)
+				require.NotNil(t, m)
+				assert.Equal(t, want.content, string(m.Content))
+				assert.Len(t, m.Submatch, want.count)
+
+				var bs [1]byte
+				n, _ := p.Read(bs[:])
+				assert.Equal(t, want.rest, string(bs[:n]))
+			})
+		}
+	}
+}
+
+func TestSeparated_Min(t *testing.T) {
+	t.Parallel()
+
+	letter := byteIn('a', 'b', 'c')
+	comma := byteIn(',')
+
+	for _, mtch := range []parser.Matcher{
+		match.ManyWithSep(token.Literal, 3, letter, comma),
+		match.SepEndBy(token.Literal, 3, letter, comma),
+		match.EndBy(token.Literal, 3, letter, comma),
+	} {
+		p := parser.New(strings.NewReader("a,b,"))
+		m, err := mtch.Match(p)
+		assert.NoError(t, err)
+		assert.Nil(t, m)
+
+		// input is restored
+		m, err = letter.Match(p)
+		assert.NoError(t, err)
+		assert.NotNil(t, m)
+	}
+}
+
+func TestMap(t *testing.T) {
+	t.Parallel()
+
+	number := match.Map(
+		match.Many(token.Literal, 1, digit),
+		func(m *parser.Match) (interface{}, error) {
+			return strconv.Atoi(string(m.Content))
+		},
+	)
+
+	p := parser.New(strings.NewReader("1234x"))
+	m, err := number.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "1234", string(m.Content))
+	assert.Equal(t, 1234, m.Made)
+
+	m, err = number.Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestMap_Error(t *testing.T) {
+	t.Parallel()
+
+	errBad := errors.New("bad")
+	bad := match.Map(digit, func(*parser.Match) (interface{}, error) {
+		return nil, errBad
+	})
+
+	p := parser.New(strings.NewReader("1"))
+	m, err := bad.Match(p)
+	assert.ErrorIs(t, err, errBad)
+	assert.Nil(t, m)
+}
+
+func TestSeqNamed_Misuse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args []any
+		want string
+	}{
+		{"odd", []any{"a", digit, "b"},
+			"SeqNamed: expected name and matcher pairs, but got 3 arguments"},
+		{"name not string", []any{"a", digit, 42, digit},
+			"SeqNamed: argument 2 must be a string name, but got int"},
+		{"not a matcher", []any{"a", digit, "b", "c"},
+			"SeqNamed: argument 3 must be a parser.Matcher, but got string"},
+		{"nil matcher", []any{"a", nil},
+			"SeqNamed: argument 1 must be a parser.Matcher, but got <nil>"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.PanicsWithValue(t, tt.want, func() {
+				match.SeqNamed(token.Literal, tt.args...)
+			})
+		})
+	}
+}
+
+func TestSeqOptional(t *testing.T) {
+	t.Parallel()
+
+	var (
+		TMethod  = token.NextTag()
+		TPath    = token.NextTag()
+		TVersion = token.NextTag()
+	)
+
+	word := func(t token.Tag) parser.Matcher {
+		return match.NBytes(t, 1, 10, match.BytesInRange('A', 'Z'))
+	}
+	space := match.ByteSlice(token.Literal, []byte(" "))
+
+	// a request line where the path and version are optional
+	line := match.SeqOptional(token.Literal,
+		match.Required(word(TMethod)),
+		match.Maybe(match.Seq(TPath, space, match.ByteSlice(TPath, []byte("/")))),
+		match.Maybe(match.Seq(TVersion, space, word(TVersion))),
+		match.Required(match.EOF()),
+	)
+
+	tests := []struct {
+		input string
+		tags  []token.Tag
+	}{
+		{"GET / HTTP", []token.Tag{TMethod, TPath, TVersion, token.None}},
+		{"GET /", []token.Tag{TMethod, TPath, token.None}},
+		{"GET HTTP", []token.Tag{TMethod, TVersion, token.None}},
+		{"GET", []token.Tag{TMethod, token.None}},
+		{"GET x", nil},
+		{"/", nil},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := line.Match(p)
+			assert.NoError(t, err)
+			if tt.tags == nil {
+				assert.Nil(t, m)
+
+				// input is restored
+				var bs [1]byte
+				_, err = p.Read(bs[:])
+				assert.NoError(t, err)
+				assert.Equal(t, tt.input[:1], string(bs[:]))
+				return
+			}
+
+			require.NotNil(t, m)
+			assert.Equal(t, tt.input, string(m.Content))
+
+			tags := make([]token.Tag, len(m.Submatch))
+			for i, sm := range m.Submatch {
+				tags[i] = sm.Tag
+			}
+			assert.Equal(t, tt.tags, tags)
+		})
+	}
+}
+
+func TestExpect(t *testing.T) {
+	t.Parallel()
+
+	letters := match.NBytes(token.Literal, 0, 20, match.BytesInRange('a', 'z'))
+	newline := match.OneByte(token.Literal, match.BytesInSet('\n'))
+	assignment := match.Seq(token.Literal,
+		letters,
+		match.ByteSlice(token.Literal, []byte("=")),
+		match.Expect("digit", digit),
+		newline,
+	)
+
+	p := parser.New(strings.NewReader("a=1\nb=2\nvalue=x\n"))
+	for i := 0; i < 2; i++ {
+		m, err := match.TryAndKeep(assignment).Match(p)
+		require.NoError(t, err)
+		require.NotNil(t, m)
+	}
+
+	m, err := assignment.Match(p)
+	assert.Nil(t, m)
+
+	var perr *parser.ParseError
+	require.ErrorAs(t, err, &perr)
+	assert.Equal(t, "expected digit at line 3 col 7", err.Error())
+	assert.Equal(t, 14, perr.Offset)
+	assert.Equal(t, 3, perr.Line)
+	assert.Equal(t, 7, perr.Column)
+	assert.Equal(t, []string{"digit"}, perr.Expected)
+}
+
+func TestMatch_Span(t *testing.T) {
+	t.Parallel()
+
+	letters := match.NBytes(token.Literal, 1, 10, match.BytesInRange('a', 'z'))
+	pair := match.Seq(token.Literal,
+		letters,
+		match.OneByte(token.Literal, match.BytesInSet('=')),
+		match.NRunes(token.Literal, 1, 10, match.RunesInRange('0', '9')),
+	)
+	pairs := match.ManyWithSep(token.Literal, 1, pair,
+		match.OneByte(token.Literal, match.BytesInSet(';')))
+
+	p := parser.New(strings.NewReader("# ab=12;c=3"))
+
+	// the comment is kept, so offsets must count input already collected
+	m, err := match.ByteSlice(token.Literal, []byte("# ")).Match(p)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+
+	m, err = pairs.Match(p)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+
+	start, end := m.Span()
+	assert.Equal(t, 2, start)
+	assert.Equal(t, 11, end)
+
+	var within func(m *parser.Match)
+	within = func(m *parser.Match) {
+		for _, sm := range m.Submatch {
+			assert.GreaterOrEqual(t, sm.Start, m.Start)
+			assert.LessOrEqual(t, sm.End, m.End)
+			assert.Equal(t, sm.Length(), sm.End-sm.Start)
+			within(sm)
+		}
+	}
+	within(m)
+
+	second := m.Submatch[1]
+	assert.Equal(t, 8, second.Start)
+	assert.Equal(t, 11, second.End)
+	assert.Equal(t, 10, second.Submatch[2].Start)
+
+	var none *parser.Match
+	start, end = none.Span()
+	assert.Equal(t, 0, start)
+	assert.Equal(t, 0, end)
+}
+
+func TestMatch_SpanEach(t *testing.T) {
+	t.Parallel()
+
+	zz := match.String(token.Literal, "zz")
+
+	tests := []struct {
+		name       string
+		mtch       parser.Matcher
+		input      string
+		start, end int
+	}{
+		{"Optional", match.Optional(zz), "abcd", 2, 2},
+		{"OptionalDefault", match.OptionalDefault(zz, 1), "abcd", 2, 2},
+		{"Not", match.Not(zz), "abcd", 2, 2},
+		{"Peek", match.Peek(match.String(token.Literal, "cd")), "abcd", 2, 2},
+		{"EOF", match.EOF(), "ab", 2, 2},
+		{"PrintableText", match.PrintableText(token.Literal, 1), "abcd", 2, 4},
+		{"OrRestOfLine", match.OrRestOfLine(token.Literal, zz), "abcd\n", 2, 4},
+		{"FoldedValue", match.FoldedValue(token.Literal), "abcd\n e\n", 2, 7},
+		{"QuotedOrBare quoted", match.QuotedOrBare(token.Literal, match.BytesInSet(' ')), "ab\"x\" ", 2, 5},
+		{"QuotedOrBare bare", match.QuotedOrBare(token.Literal, match.BytesInSet(' ')), "abxy ", 2, 4},
+		{"ChecksumDigits", match.LuhnNumber(token.Literal), "ab79927398713", 2, 13},
+		{"Sign", match.Sign(token.Literal), "ab-1", 2, 3},
+		{"Sign none", match.Sign(token.Literal), "ab1", 2, 2},
+		{"TagBalance", match.TagBalance(), "abcd", 2, 2},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := match.Seq(token.Literal,
+				match.String(token.Literal, "ab"), tt.mtch).Match(p)
+			require.NoError(t, err)
+			require.NotNil(t, m)
+			require.Len(t, m.Submatch, 2)
+
+			sm := m.Submatch[1]
+			assert.Equal(t, tt.start, sm.Start, "start")
+			assert.Equal(t, tt.end, sm.End, "end")
+			assert.GreaterOrEqual(t, sm.Start, m.Start)
+			assert.LessOrEqual(t, sm.End, m.End)
+		})
+	}
+}
+
+func TestManySafe(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("123x"))
+	m, err := match.ManySafe(token.Literal, 1, digit).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "123", string(m.Content))
+	assert.Len(t, m.Submatch, 3)
+
+	// the x was not consumed by the failed iteration
+	m, err = byteIn('x').Match(p)
+	assert.NoError(t, err)
+	assert.NotNil(t, m)
+
+	p = parser.New(strings.NewReader("12x"))
+	m, err = match.ManySafe(token.Literal, 3, digit).Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	m, err = byteIn('1').Match(p)
+	assert.NoError(t, err)
+	assert.NotNil(t, m)
+}
+
+func TestManySafe_NoProgress(t *testing.T) {
+	t.Parallel()
+
+	// Optional always matches, so this would repeat forever at the x
+	p := parser.New(strings.NewReader("12x"))
+	m, err := match.ManySafe(token.Literal, 0, match.Optional(digit)).Match(p)
+	assert.ErrorIs(t, err, match.ErrNoProgress)
+	assert.ErrorContains(t, err, "offset 2 after 2 matches")
+	assert.Nil(t, m)
+
+	p = parser.New(strings.NewReader("12x"))
+	m, err = match.ManySafeLenient(token.Literal, 0, match.Optional(digit)).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "12", string(m.Content))
+	assert.Equal(t, 2, p.Offset())
+}
+
+func TestExpect_Fields(t *testing.T) {
+	t.Parallel()
+
+	letters := match.NBytes(token.Literal, 1, 20, match.BytesInRange('a', 'z'))
+	call := match.Seq(token.Literal,
+		letters,
+		match.ByteSlice(token.Literal, []byte("(")),
+		match.Optional(letters),
+		match.Expect(`")"`, match.ByteSlice(token.Literal, []byte(")"))),
+	)
+
+	p := parser.New(strings.NewReader("f(x;"))
+	m, err := call.Match(p)
+	assert.Nil(t, m)
+
+	var perr *parser.ParseError
+	require.ErrorAs(t, err, &perr)
+	assert.Equal(t, &parser.ParseError{
+		Offset:   3,
+		Line:     1,
+		Column:   4,
+		Message:  `expected ")"`,
+		Expected: []string{`")"`},
+	}, perr)
+	assert.EqualError(t, err, `expected ")" at line 1 col 4`)
+}
+
+func TestLabel_FurthestFailure(t *testing.T) {
+	t.Parallel()
+
+	letter := match.Label("letter", byteIn([]byte("abcdefghijklmnopqrstuvwxyz")...))
+	number := match.Label("digit", digit)
+	plus := match.Label(`"+"`, byteIn('+'))
+
+	// a sum is a digit, then "+" and a letter or digit
+	sum := match.Seq(token.Literal,
+		number,
+		plus,
+		match.First(letter, number),
+	)
+
+	p := parser.New(strings.NewReader("1+*"))
+	offset, expected := p.FurthestFailure()
+	assert.Equal(t, -1, offset)
+	assert.Nil(t, expected)
+
+	m, err := match.TryAndKeep(sum).Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	offset, expected = p.FurthestFailure()
+	assert.Equal(t, 2, offset)
+	assert.Equal(t, []string{"letter", "digit"}, expected)
+
+	// failing earlier does not replace the furthest failure
+	m, err = match.Label("sum", letter).Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	offset, expected = p.FurthestFailure()
+	assert.Equal(t, 2, offset)
+	assert.Equal(t, []string{"letter", "digit"}, expected)
+}
+
+func TestExpect_FurthestFailure(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("x"))
+	_, err := match.Expect("digit", digit).Match(p)
+	assert.Error(t, err)
+
+	offset, expected := p.FurthestFailure()
+	assert.Equal(t, 0, offset)
+	assert.Equal(t, []string{"digit"}, expected)
+}
+
+func TestMatch_Find(t *testing.T) {
+	t.Parallel()
+
+	grammar, tags := contactInfo()
+
+	p := parser.New(strings.NewReader("555-123-4567"))
+	m, err := grammar.Match(p)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+
+	assert.Same(t, m, m.Find(tags.PhoneNumber))
+	assert.Equal(t, "555", string(m.Find(tags.AreaCode).Content))
+	assert.Equal(t, "123", string(m.Find(tags.LocalCode).Content))
+	assert.Equal(t, "4567", string(m.Find(tags.PersonalCode).Content))
+	assert.Nil(t, m.Find(tags.EmailAddress))
+	assert.Empty(t, m.FindAll(tags.DotAtom))
+
+	// the two hyphens, in order
+	hyphens := m.FindAll(token.Literal)
+	require.Len(t, hyphens, 2)
+	assert.Equal(t, 3, hyphens[0].Start)
+	assert.Equal(t, 7, hyphens[1].Start)
+
+	p = parser.New(strings.NewReader("jane.doe@example.com"))
+	m, err = grammar.Match(p)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+
+	atoms := m.FindAll(tags.DotAtom)
+	require.Len(t, atoms, 2)
+	assert.Equal(t, "jane.doe", string(atoms[0].Content))
+	assert.Equal(t, "example.com", string(atoms[1].Content))
+	assert.Same(t, atoms[0], m.Find(tags.DotAtom))
+
+	var none *parser.Match
+	assert.Nil(t, none.Find(tags.DotAtom))
+	assert.Nil(t, none.FindAll(tags.DotAtom))
 }
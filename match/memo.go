@@ -0,0 +1,55 @@
+package match
+
+import "github.com/zostay/gordy/parser"
+
+// memoized is the Matcher returned by Memoize. Its address identifies its
+// results in the parser.Memo.
+type memoized struct {
+	mtch parser.Matcher
+}
+
+// Memoize returns a Matcher that remembers the result of the given Matcher at
+// each offset in the input (see parser.Memo). When it is tried again at the
+// same offset, the remembered result is returned and the input is moved
+// forward just as before, without matching again. This turns a grammar that
+// tries the same alternatives over and over, which may take exponential time,
+// into one that takes linear time.
+//
+// The returned Match is shared by every use of the result, so it must not be
+// modified. Do not memoize a Matcher whose result depends on anything other
+// than the input, such as state set with parser.Input.SetValue, as that state
+// is not remembered.
+func Memoize(mtch parser.Matcher) parser.Matcher {
+	return &memoized{mtch}
+}
+
+// Match returns the remembered result of the Matcher at this offset or matches
+// and remembers the result.
+func (mm *memoized) Match(p *parser.Input) (*parser.Match, error) {
+	memo := p.Memo()
+	start := p.Offset()
+
+	if e, ok := memo.Get(mm, start); ok {
+		if e.Match != nil && e.End > start {
+			bs := make([]byte, e.End-start)
+			if _, err := p.Read(bs); err != nil {
+				return nil, err
+			}
+		}
+
+		p.Trace(parser.StageGot, "Memoize", mm.mtch, e.Match)
+		return e.Match, e.Err
+	}
+
+	c := p.MayFail()
+	m, err := mm.mtch.Match(c)
+
+	e := parser.MemoEntry{Match: m, Err: err, End: start}
+	if err == nil && m != nil {
+		e.End = c.Offset()
+		c.Keep()
+	}
+
+	memo.Put(mm, start, e)
+	return m, err
+}
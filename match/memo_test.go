@@ -0,0 +1,132 @@
+package match_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/gordy/match"
+	"github.com/zostay/gordy/parser"
+	"github.com/zostay/gordy/token"
+)
+
+// nestedGrammar builds a grammar that tries the same term three times at each
+// level of nesting:
+//
+//	expr := term "+" expr | term "-" expr | term
+//	term := "(" expr ")" | digit
+//
+// Without memoization, it takes time exponential in the depth of nesting.
+func nestedGrammar(memoize bool, calls *int) parser.Matcher {
+	wrap := func(m parser.Matcher) parser.Matcher {
+		if memoize {
+			return match.Memoize(m)
+		}
+		return m
+	}
+
+	expr := &match.Rule{Name: "expr"}
+	term := &match.Rule{Name: "term"}
+
+	counted := parser.MatcherFunc(func(p *parser.Input) (*parser.Match, error) {
+		*calls++
+		return term.Match(p)
+	})
+	t := wrap(counted)
+	e := wrap(expr)
+
+	lit := func(s string) parser.Matcher {
+		return match.ByteSlice(token.Literal, []byte(s))
+	}
+
+	expr.Matcher = match.First(
+		match.TryAndKeep(match.Seq(token.Literal, t, lit("+"), e)),
+		match.TryAndKeep(match.Seq(token.Literal, t, lit("-"), e)),
+		t,
+	)
+	term.Matcher = match.First(
+		match.TryAndKeep(match.Seq(token.Literal, lit("("), e, lit(")"))),
+		match.OneByte(token.Literal, match.BytesInRange('0', '9')),
+	)
+
+	return match.Seq(token.Literal, e, match.EOF())
+}
+
+func nestedInput(depth int) string {
+	return strings.Repeat("(", depth) + "1" + strings.Repeat(")", depth) + "+2"
+}
+
+func TestMemoize(t *testing.T) {
+	t.Parallel()
+
+	in := nestedInput(6)
+
+	var plain, memo int
+	for _, tc := range []struct {
+		memoize bool
+		calls   *int
+	}{{false, &plain}, {true, &memo}} {
+		p := parser.New(strings.NewReader(in))
+		m, err := nestedGrammar(tc.memoize, tc.calls).Match(p)
+		require.NoError(t, err)
+		require.NotNil(t, m)
+		assert.Equal(t, in, string(m.Content))
+	}
+
+	// the term at each offset is matched at most once
+	assert.LessOrEqual(t, memo, len(in))
+	assert.Greater(t, plain, 100*memo)
+}
+
+func TestMemoize_Failure(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	counted := match.Memoize(parser.MatcherFunc(
+		func(p *parser.Input) (*parser.Match, error) {
+			calls++
+			return digit.Match(p)
+		}))
+
+	p := parser.New(strings.NewReader("x1"))
+	for i := 0; i < 3; i++ {
+		m, err := counted.Match(p)
+		assert.NoError(t, err)
+		assert.Nil(t, m)
+	}
+	assert.Equal(t, 1, calls)
+
+	// input is restored after a failure
+	m, err := byteIn('x').Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+
+	m, err = counted.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "1", string(m.Content))
+	assert.Equal(t, 2, calls)
+}
+
+func BenchmarkNestedGrammar(b *testing.B) {
+	in := nestedInput(8)
+
+	for _, bc := range []struct {
+		name    string
+		memoize bool
+	}{{"Plain", false}, {"Memoize", true}} {
+		bc := bc
+		b.Run(bc.name, func(b *testing.B) {
+			var calls int
+			g := nestedGrammar(bc.memoize, &calls)
+			for i := 0; i < b.N; i++ {
+				p := parser.New(strings.NewReader(in))
+				if m, err := g.Match(p); err != nil || m == nil {
+					b.Fatal("failed to match", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,407 @@
+package match
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/zostay/gordy/parser"
+	"github.com/zostay/gordy/token"
+)
+
+// isDigit is the BytePredicate for decimal digits.
+var isDigit = BytesInRange('0', '9')
+
+// Luhn reports whether the given decimal digits pass the Luhn mod-10 check
+// used for credit card numbers, IMEIs, and the like.
+func Luhn(digits []byte) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+
+		sum += d
+		double = !double
+	}
+
+	return sum%10 == 0
+}
+
+// ChecksumDigits returns a Matcher that matches a run of decimal digits, but
+// only if the digits pass the given checksum algorithm. The returned Match
+// has the given token.Tag and the string of digits in Made. If there are no
+// digits or the check fails, it returns nil and the input is restored.
+func ChecksumDigits(
+	t token.Tag,
+	algo func([]byte) bool,
+) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		p = p.MayFail()
+		start := p.Offset()
+
+		ds, err := readWhile(p, isDigit)
+		if err != nil {
+			p.Trace(parser.StageFail, "ChecksumDigits", t, algo, err)
+			return nil, err
+		}
+
+		if len(ds) == 0 || !algo(ds) {
+			return nil, nil
+		}
+
+		end := p.Offset()
+		p.Keep()
+
+		m := &parser.Match{
+			Tag:     t,
+			Content: ds,
+			Made:    string(ds),
+			Start:   start,
+			End:     end,
+		}
+		p.Trace(parser.StageGot, "ChecksumDigits", t, algo, m)
+		return m, nil
+	}
+}
+
+// LuhnNumber returns a Matcher that matches a run of decimal digits that pass
+// the Luhn mod-10 check. It works like ChecksumDigits using Luhn.
+func LuhnNumber(t token.Tag) parser.Matcher {
+	return ChecksumDigits(t, Luhn)
+}
+
+// isNumberStart is the BytePredicate for the bytes that may follow a sign.
+var isNumberStart = AnyBytes(isDigit, BytesInSet('.'))
+
+// Sign returns a Matcher that matches an optional sign, "+" or "-", at the
+// start of a number. The returned Match has the given token.Tag and Made is
+// set to the polarity, either +1 or -1 as an int. The sign is only matched if
+// it is immediately followed by a digit or a decimal point, so a "-" that is
+// really a separator is left alone. When there is no sign, Sign still matches,
+// consuming nothing, with a polarity of +1.
+func Sign(t token.Tag) parser.MatcherFunc {
+	signed := Seq(token.Literal,
+		OneByte(token.Literal, BytesInSet('+', '-')),
+		Peek(OneByte(token.Literal, isNumberStart)),
+	)
+
+	return func(p *parser.Input) (*parser.Match, error) {
+		start := p.Offset()
+		m, err := TryAndKeep(signed).Match(p)
+		if err != nil {
+			p.Trace(parser.StageFail, "Sign", t, err)
+			return nil, err
+		}
+
+		if m == nil {
+			return &parser.Match{
+				Tag:     t,
+				Content: []byte{},
+				Made:    +1,
+				Start:   start,
+				End:     start,
+			}, nil
+		}
+
+		polarity := +1
+		if m.Content[0] == '-' {
+			polarity = -1
+		}
+
+		m = &parser.Match{
+			Tag:     t,
+			Content: m.Content,
+			Made:    polarity,
+			Start:   start,
+			End:     p.Offset(),
+		}
+		p.Trace(parser.StageGot, "Sign", t, m)
+		return m, nil
+	}
+}
+
+// Float returns a Matcher that matches a decimal floating-point literal: an
+// optional sign, an integer part, a fractional part, and an exponent, such as
+// "-1.5e3". Either the integer or the fractional part may be omitted, but not
+// both. A decimal point is only matched when followed by a digit, so "42."
+// matches "42" and leaves the "." in the input. Likewise, an exponent is only
+// matched when it has digits. The returned Match has the given token.Tag and
+// the value as a float64 in Made. If the value does not fit in a float64, a
+// *parser.ParseError is returned. If no number is found, it returns nil and the
+// input is restored.
+func Float(t token.Tag) parser.MatcherFunc {
+	point := OneByte(token.Literal, BytesInSet('.'))
+	exp := OneByte(token.Literal, BytesInSet('e', 'E'))
+	expSign := OneByte(token.Literal, BytesInSet('+', '-'))
+
+	return func(p *parser.Input) (*parser.Match, error) {
+		c := p.MayFail()
+		start := c.Offset()
+
+		sm, err := Sign(token.Literal).Match(c)
+		if err != nil {
+			p.Trace(parser.StageFail, "Float", t, err)
+			return nil, err
+		}
+
+		content := append([]byte{}, sm.Content...)
+
+		ds, err := readWhile(c, isDigit)
+		if err != nil {
+			p.Trace(parser.StageFail, "Float", t, err)
+			return nil, err
+		}
+		content = append(content, ds...)
+
+		fc := c.MayFail()
+		dot, err := point.Match(fc)
+		if err != nil {
+			p.Trace(parser.StageFail, "Float", t, err)
+			return nil, err
+		}
+
+		if dot != nil {
+			fs, err := readWhile(fc, isDigit)
+			if err != nil {
+				p.Trace(parser.StageFail, "Float", t, err)
+				return nil, err
+			}
+
+			if len(fs) > 0 {
+				fc.Keep()
+				content = append(content, '.')
+				content = append(content, fs...)
+				ds = append(ds, fs...)
+			}
+		}
+
+		if len(ds) == 0 {
+			return nil, nil
+		}
+
+		ec := c.MayFail()
+		e, err := exp.Match(ec)
+		if err != nil {
+			p.Trace(parser.StageFail, "Float", t, err)
+			return nil, err
+		}
+
+		if e != nil {
+			es, err := TryAndKeep(expSign).Match(ec)
+			if err != nil {
+				p.Trace(parser.StageFail, "Float", t, err)
+				return nil, err
+			}
+
+			xs, err := readWhile(ec, isDigit)
+			if err != nil {
+				p.Trace(parser.StageFail, "Float", t, err)
+				return nil, err
+			}
+
+			if len(xs) > 0 {
+				ec.Keep()
+				content = append(content, e.Content...)
+				if es != nil {
+					content = append(content, es.Content...)
+				}
+				content = append(content, xs...)
+			}
+		}
+
+		f, err := strconv.ParseFloat(string(content), 64)
+		if err != nil {
+			perr := parser.NewParseError(p, "float")
+			perr.Message = fmt.Sprintf("float %s is out of range", content)
+			p.Trace(parser.StageFail, "Float", t, perr)
+			return nil, perr
+		}
+
+		end := c.Offset()
+		c.Keep()
+
+		m := &parser.Match{
+			Tag:     t,
+			Content: content,
+			Made:    f,
+			Start:   start,
+			End:     end,
+		}
+		p.Trace(parser.StageGot, "Float", t, m)
+		return m, nil
+	}
+}
+
+// Percentage returns a Matcher that matches a number, as matched by Float,
+// optionally followed by "%". The returned Match has the given token.Tag and
+// Made is set to the value as a float64 fraction: a number followed by "%" is
+// divided by 100, so "50%" and "0.5" both have a Made of 0.5. If no number is
+// found or the number is out of range for a float64, it returns nil and the
+// input is restored.
+func Percentage(t token.Tag) parser.MatcherFunc {
+	number := Float(token.Literal)
+	percent := OneByte(token.Literal, BytesInSet('%'))
+
+	return func(p *parser.Input) (*parser.Match, error) {
+		p = p.MayFail()
+		start := p.Offset()
+
+		nm, err := number.Match(p)
+		var perr *parser.ParseError
+		if errors.As(err, &perr) {
+			// a number that cannot be parsed is not a percentage
+			p.Trace(parser.StageFail, "Percentage", t, err)
+			return nil, nil
+		} else if err != nil {
+			p.Trace(parser.StageFail, "Percentage", t, err)
+			return nil, err
+		}
+
+		if nm == nil {
+			return nil, nil
+		}
+
+		content := append([]byte{}, nm.Content...)
+		f := nm.Made.(float64)
+
+		pm, err := TryAndKeep(percent).Match(p)
+		if err != nil {
+			p.Trace(parser.StageFail, "Percentage", t, err)
+			return nil, err
+		}
+
+		if pm != nil {
+			content = append(content, pm.Content...)
+			f /= 100
+		}
+
+		end := p.Offset()
+		p.Keep()
+
+		m := &parser.Match{
+			Tag:     t,
+			Content: content,
+			Made:    f,
+			Start:   start,
+			End:     end,
+		}
+		p.Trace(parser.StageGot, "Percentage", t, m)
+		return m, nil
+	}
+}
+
+// digitIn returns the BytePredicate for the digits of the given base, where
+// the digits after 9 are the letters a to z in either case.
+func digitIn(base int) BytePredicate {
+	return func(b byte) bool {
+		var v int
+		switch {
+		case b >= '0' && b <= '9':
+			v = int(b - '0')
+		case b >= 'a' && b <= 'z':
+			v = int(b-'a') + 10
+		case b >= 'A' && b <= 'Z':
+			v = int(b-'A') + 10
+		default:
+			return false
+		}
+		return v < base
+	}
+}
+
+// intPrefixes are the base prefixes recognized by Integer with base 0.
+var intPrefixes = map[string]int{
+	"0x": 16, "0X": 16,
+	"0o": 8, "0O": 8,
+	"0b": 2, "0B": 2,
+}
+
+// Integer returns a Matcher that matches an integer literal: an optional sign
+// followed by a run of digits in the given base, which must be between 2 and
+// 36. For base 0, a "0x", "0o", or "0b" prefix selects base 16, 8, or 2 and
+// the base is 10 otherwise. A prefix is only matched when followed by a digit.
+// The returned Match has the given token.Tag and the value as an int64 in
+// Made. If the value does not fit in an int64, a *parser.ParseError is
+// returned. If there are no digits, it returns nil and the input is restored.
+func Integer(t token.Tag, base int) parser.MatcherFunc {
+	if base != 0 && (base < 2 || base > 36) {
+		panic(fmt.Sprintf("Integer: invalid base %d", base))
+	}
+
+	return func(p *parser.Input) (*parser.Match, error) {
+		c := p.MayFail()
+		start := c.Offset()
+
+		sm, err := Sign(token.Literal).Match(c)
+		if err != nil {
+			p.Trace(parser.StageFail, "Integer", t, base, err)
+			return nil, err
+		}
+
+		content := append([]byte{}, sm.Content...)
+
+		b := base
+		var ds []byte
+		if base == 0 {
+			b = 10
+
+			pc := c.MayFail()
+			var prefix [2]byte
+			if n, _ := pc.Read(prefix[:]); n == 2 && intPrefixes[string(prefix[:])] != 0 {
+				pb := intPrefixes[string(prefix[:])]
+				ds, err = readWhile(pc, digitIn(pb))
+				if err != nil {
+					p.Trace(parser.StageFail, "Integer", t, base, err)
+					return nil, err
+				}
+
+				if len(ds) > 0 {
+					pc.Keep()
+					b = pb
+					content = append(content, prefix[:]...)
+				}
+			}
+		}
+
+		if len(ds) == 0 {
+			ds, err = readWhile(c, digitIn(b))
+			if err != nil {
+				p.Trace(parser.StageFail, "Integer", t, base, err)
+				return nil, err
+			}
+		}
+
+		if len(ds) == 0 {
+			return nil, nil
+		}
+		content = append(content, ds...)
+
+		num := append(append([]byte{}, sm.Content...), ds...)
+		v, err := strconv.ParseInt(string(num), b, 64)
+		if err != nil {
+			perr := parser.NewParseError(p, "integer")
+			perr.Message = fmt.Sprintf("integer %s is out of range", content)
+			p.Trace(parser.StageFail, "Integer", t, base, perr)
+			return nil, perr
+		}
+
+		end := c.Offset()
+		c.Keep()
+
+		m := &parser.Match{
+			Tag:     t,
+			Content: content,
+			Made:    v,
+			Start:   start,
+			End:     end,
+		}
+		p.Trace(parser.StageGot, "Integer", t, base, m)
+		return m, nil
+	}
+}
@@ -0,0 +1,284 @@
+package match_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/gordy/match"
+	"github.com/zostay/gordy/parser"
+	"github.com/zostay/gordy/token"
+)
+
+func TestLuhn(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, match.Luhn([]byte("79927398713")))
+	assert.True(t, match.Luhn([]byte("4111111111111111")))
+	assert.False(t, match.Luhn([]byte("79927398710")))
+	assert.False(t, match.Luhn([]byte("4111111111111112")))
+}
+
+func TestLuhnNumber(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("79927398713 rest"))
+	m, err := match.LuhnNumber(token.Literal).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "79927398713", string(m.Content))
+	assert.Equal(t, "79927398713", m.Made)
+
+	p = parser.New(strings.NewReader("79927398710"))
+	m, err = match.LuhnNumber(token.Literal).Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	// input is restored
+	m, err = match.OneByte(token.Literal, match.BytesInSet('7')).Match(p)
+	assert.NoError(t, err)
+	assert.NotNil(t, m)
+}
+
+func TestChecksumDigits(t *testing.T) {
+	t.Parallel()
+
+	// weighted mod-11 check, as used by ISBN-10 (without the X check digit)
+	mod11 := func(ds []byte) bool {
+		sum := 0
+		for i, d := range ds {
+			sum += (len(ds) - i) * int(d-'0')
+		}
+		return len(ds) == 10 && sum%11 == 0
+	}
+
+	isbn := match.ChecksumDigits(token.Literal, mod11)
+
+	p := parser.New(strings.NewReader("0306406152"))
+	m, err := isbn.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "0306406152", m.Made)
+
+	p = parser.New(strings.NewReader("0306406153"))
+	m, err = isbn.Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	p = parser.New(strings.NewReader("abc"))
+	m, err = isbn.Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestSign(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input    string
+		content  string
+		polarity int
+		rest     string
+	}{
+		{"+1", "+", +1, "1"},
+		{"-1", "-", -1, "1"},
+		{"-.5", "-", -1, "."},
+		{"1", "", +1, "1"},
+		{"- 1", "", +1, "-"},
+		{"-x", "", +1, "-"},
+		{"-", "", +1, "-"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := match.Sign(token.Literal).Match(p)
+			assert.NoError(t, err)
+			require.NotNil(t, m)
+			assert.Equal(t, tt.content, string(m.Content))
+			assert.Equal(t, tt.polarity, m.Made)
+
+			var bs [1]byte
+			_, err = p.Read(bs[:])
+			assert.NoError(t, err)
+			assert.Equal(t, tt.rest, string(bs[:]))
+		})
+	}
+}
+
+func TestPercentage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input   string
+		content string
+		want    float64
+	}{
+		{"50%", "50%", 0.5},
+		{"0.5", "0.5", 0.5},
+		{".5", ".5", 0.5},
+		{"-25%", "-25%", -0.25},
+		{"+12.5% off", "+12.5%", 0.125},
+		{"3.", "3", 3},
+		{"100", "100", 1e2},
+		{"abc", "", 0},
+		{"%", "", 0},
+		{"-.", "", 0},
+		{"1e400%", "", 0},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := match.Percentage(token.Literal).Match(p)
+			assert.NoError(t, err)
+			if tt.content == "" {
+				assert.Nil(t, m)
+
+				// input is restored
+				var bs [1]byte
+				_, err = p.Read(bs[:])
+				assert.NoError(t, err)
+				assert.Equal(t, tt.input[:1], string(bs[:]))
+				return
+			}
+
+			require.NotNil(t, m)
+			assert.Equal(t, tt.content, string(m.Content))
+			assert.InDelta(t, tt.want, m.Made, 1e-9)
+		})
+	}
+}
+
+func TestFloat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input   string
+		content string
+		want    float64
+	}{
+		{"3.14", "3.14", 3.14},
+		{"1e10", "1e10", 1e10},
+		{"-0.5", "-0.5", -0.5},
+		{".5", ".5", 0.5},
+		{"42.", "42", 42},
+		{"2.5E-3;", "2.5E-3", 2.5e-3},
+		{"+6e+2", "+6e+2", 600},
+		{"7e", "7", 7},
+		{"7e-x", "7", 7},
+		{"x", "", 0},
+		{".", "", 0},
+		{"-.", "", 0},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := match.Float(token.Literal).Match(p)
+			assert.NoError(t, err)
+			if tt.content == "" {
+				assert.Nil(t, m)
+				return
+			}
+
+			require.NotNil(t, m)
+			assert.Equal(t, tt.content, string(m.Content))
+			assert.InDelta(t, tt.want, m.Made, 1e-9)
+
+			// whatever follows the number is still in the input
+			rest := tt.input[len(tt.content):]
+			if rest != "" {
+				bs := make([]byte, len(rest))
+				_, err = p.Read(bs)
+				assert.NoError(t, err)
+				assert.Equal(t, rest, string(bs))
+			}
+		})
+	}
+}
+
+func TestFloat_OutOfRange(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("1e400"))
+	m, err := match.Float(token.Literal).Match(p)
+	assert.Nil(t, m)
+	assert.EqualError(t, err, "float 1e400 is out of range at line 1 col 1")
+}
+
+func TestInteger(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input   string
+		base    int
+		content string
+		want    int64
+	}{
+		{"42", 10, "42", 42},
+		{"-42;", 10, "-42", -42},
+		{"+7", 10, "+7", 7},
+		{"0x1F", 0, "0x1F", 31},
+		{"-0x10", 0, "-0x10", -16},
+		{"0o17", 0, "0o17", 15},
+		{"0b101", 0, "0b101", 5},
+		{"017", 0, "017", 17},
+		{"0xg", 0, "0", 0},
+		{"ff", 16, "ff", 255},
+		{"129", 8, "12", 10},
+		{"9223372036854775807", 10, "9223372036854775807", 9223372036854775807},
+		{"x", 10, "", 0},
+		{"-", 10, "", 0},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := match.Integer(token.Literal, tt.base).Match(p)
+			assert.NoError(t, err)
+			if tt.content == "" {
+				assert.Nil(t, m)
+				return
+			}
+
+			require.NotNil(t, m)
+			assert.Equal(t, tt.content, string(m.Content))
+			assert.Equal(t, tt.want, m.Made)
+		})
+	}
+}
+
+func TestInteger_Overflow(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("x=9223372036854775808"))
+	m, err := match.Seq(token.Literal,
+		match.ByteSlice(token.Literal, []byte("x=")),
+		match.Integer(token.Literal, 10),
+	).Match(p)
+	assert.Nil(t, m)
+
+	var perr *parser.ParseError
+	require.ErrorAs(t, err, &perr)
+	assert.Equal(t, 2, perr.Offset)
+	assert.Equal(t, []string{"integer"}, perr.Expected)
+	assert.EqualError(t, err,
+		"integer 9223372036854775808 is out of range at line 1 col 3")
+
+	assert.Panics(t, func() { match.Integer(token.Literal, 37) })
+}
@@ -0,0 +1,43 @@
+package match
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/zostay/gordy/parser"
+)
+
+// ParseString matches the given Matcher against the whole of the given string.
+// It returns the Match if the Matcher matched all of it. If the Matcher does
+// not match, it returns nil. If input remains after the Match, it returns an
+// error wrapping ErrTrailingInput naming where the remaining input starts.
+func ParseString(s string, mtch parser.Matcher) (*parser.Match, error) {
+	return parseAll(parser.New(strings.NewReader(s)), mtch)
+}
+
+// ParseBytes works just like ParseString, but matches against a byte slice.
+func ParseBytes(b []byte, mtch parser.Matcher) (*parser.Match, error) {
+	return parseAll(parser.New(bytes.NewReader(b)), mtch)
+}
+
+// parseAll matches the Matcher against the input and makes sure all of the
+// input was matched.
+func parseAll(p *parser.Input, mtch parser.Matcher) (*parser.Match, error) {
+	m, err := mtch.Match(p)
+	if err != nil || m == nil {
+		return nil, err
+	}
+
+	end, err := EOF().Match(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if end == nil {
+		_, line, col := p.Position()
+		return nil, fmt.Errorf("%w at line %d col %d", ErrTrailingInput, line, col)
+	}
+
+	return m, nil
+}
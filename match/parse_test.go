@@ -0,0 +1,46 @@
+package match_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/gordy/match"
+	"github.com/zostay/gordy/token"
+)
+
+func TestParseString(t *testing.T) {
+	t.Parallel()
+
+	number := match.NBytes(token.Literal, 1, 10, match.BytesInRange('0', '9'))
+
+	m, err := match.ParseString("123", number)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "123", string(m.Content))
+
+	m, err = match.ParseString("123abc", number)
+	assert.ErrorIs(t, err, match.ErrTrailingInput)
+	assert.EqualError(t, err, "trailing input at line 1 col 4")
+	assert.Nil(t, m)
+
+	m, err = match.ParseString("abc", number)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestParseBytes(t *testing.T) {
+	t.Parallel()
+
+	number := match.NBytes(token.Literal, 1, 10, match.BytesInRange('0', '9'))
+
+	m, err := match.ParseBytes([]byte("42"), number)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "42", string(m.Content))
+
+	m, err = match.ParseBytes([]byte("42\n"), number)
+	assert.ErrorIs(t, err, match.ErrTrailingInput)
+	assert.Nil(t, m)
+}
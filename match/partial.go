@@ -0,0 +1,144 @@
+package match
+
+import (
+	"github.com/zostay/gordy/parser"
+	"github.com/zostay/gordy/token"
+)
+
+// Partial is stored in the Made field of a Match returned by BestEffort when
+// the Matcher given to it failed. Every incomplete Match in the returned tree
+// is marked this way.
+type Partial struct{}
+
+// partials keeps track of the furthest partial match built by the sequence
+// matchers while a BestEffort is running.
+type partials struct {
+	best *parser.Match
+}
+
+var partialsKey = &stateKey{"BestEffort"}
+
+// partialsFor returns the partials tracker for the running BestEffort or nil
+// if there is none.
+func partialsFor(p *parser.Input) *partials {
+	pt, _ := p.Value(partialsKey).(*partials)
+	return pt
+}
+
+// start is called before each element of a sequence is matched. It returns the
+// best partial recorded so far, to be passed to done or fail.
+func (pt *partials) start() *parser.Match {
+	if pt == nil {
+		return nil
+	}
+
+	saved := pt.best
+	pt.best = nil
+	return saved
+}
+
+// done is called after a sequence succeeds to restore the best partial that
+// was recorded before the sequence started.
+func (pt *partials) done(saved *parser.Match) {
+	if pt == nil {
+		return
+	}
+
+	pt.best = saved
+}
+
+// fail is called when a sequence that started at the given offset fails at the
+// given offset after matching the given submatches. The submatches and the
+// best partial of the failed element are used to build a partial match of the
+// sequence, which replaces the saved partial if it made more progress through
+// the input.
+func (pt *partials) fail(
+	saved *parser.Match,
+	t token.Tag,
+	names []string,
+	ms []*parser.Match,
+	start, end int,
+) {
+	if pt == nil {
+		return
+	}
+
+	mps := make([]any, 0, len(ms)*2+2)
+	for i, m := range ms {
+		var name string
+		if names != nil {
+			name = names[i]
+		}
+		mps = append(mps, name, m)
+	}
+
+	if pt.best != nil {
+		var name string
+		if names != nil {
+			name = names[len(ms)]
+		}
+		mps = append(mps, name, pt.best)
+
+		if pt.best.End > end {
+			end = pt.best.End
+		}
+	}
+
+	m := parser.BuildMatch(t, mps...)
+	m.Made = Partial{}
+	m.Start, m.End = start, end
+
+	if saved != nil && saved.End >= m.End {
+		pt.best = saved
+		return
+	}
+
+	pt.best = m
+}
+
+// BestEffort returns a Matcher that returns the Match of the given Matcher if
+// it matches. If it fails, BestEffort returns the partial Match that made the
+// most progress through the input instead, with Partial stored in Made. The
+// input consumed by the partial Match is kept. Partial matches are built by
+// Seq and SeqNamed, so the given Matcher must be built from those to have a
+// partial result. If no partial Match was built, it returns nil.
+//
+// This is intended for uses like editors, where input is often incomplete and
+// a parse of the valid prefix is more useful than nothing.
+func BestEffort(mtch parser.Matcher) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		c := p.MayFail()
+
+		// the tracker is shared rather than copied on write: partials must
+		// survive the discarded input that produced them
+		pt := &partials{}
+		c.SetValue(partialsKey, pt)
+
+		m, err := mtch.Match(c)
+		if err != nil {
+			return nil, err
+		}
+
+		if m != nil {
+			c.Keep()
+			return m, nil
+		}
+
+		if pt.best == nil {
+			return nil, nil
+		}
+
+		// consume the input that the partial match covers
+		c = p.MayFail()
+		if n := pt.best.End - p.Offset(); n > 0 {
+			bs := make([]byte, n)
+			if _, err := c.Read(bs); err != nil {
+				return nil, err
+			}
+		}
+		c.Keep()
+
+		p.Trace(parser.StageGot, "BestEffort", mtch, pt.best)
+		return pt.best, nil
+	}
+}
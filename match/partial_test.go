@@ -0,0 +1,124 @@
+package match_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/gordy/match"
+	"github.com/zostay/gordy/parser"
+	"github.com/zostay/gordy/token"
+)
+
+func TestBestEffort(t *testing.T) {
+	t.Parallel()
+
+	TOuter := token.NextTag()
+	TInner := token.NextTag()
+
+	grammar := match.BestEffort(
+		match.Seq(TOuter,
+			byteIn('a'),
+			match.Seq(TInner, byteIn('b'), byteIn('c')),
+			byteIn('d'),
+		),
+	)
+
+	p := parser.New(strings.NewReader("abcd"))
+	m, err := grammar.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Nil(t, m.Made)
+
+	p = parser.New(strings.NewReader("abx"))
+	m, err = grammar.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+
+	assert.Equal(t, TOuter, m.Tag)
+	assert.Equal(t, match.Partial{}, m.Made)
+	assert.Equal(t, "ab", string(m.Content))
+	require.Len(t, m.Submatch, 2)
+	assert.Equal(t, "a", string(m.Submatch[0].Content))
+	assert.Equal(t, TInner, m.Submatch[1].Tag)
+	assert.Equal(t, match.Partial{}, m.Submatch[1].Made)
+	assert.Equal(t, "b", string(m.Submatch[1].Content))
+	assert.Equal(t, 0, m.Start)
+	assert.Equal(t, 2, m.End)
+	assert.Equal(t, 1, m.Submatch[1].Start)
+	assert.Equal(t, 2, m.Submatch[1].End)
+
+	// the partial input was consumed
+	m, err = byteIn('x').Match(p)
+	assert.NoError(t, err)
+	assert.NotNil(t, m)
+}
+
+func TestBestEffort_Furthest(t *testing.T) {
+	t.Parallel()
+
+	TFirst := token.NextTag()
+	TSecond := token.NextTag()
+
+	grammar := match.BestEffort(
+		match.First(
+			match.SeqNamed(TFirst,
+				"a", byteIn('a'),
+				"b", byteIn('b'),
+				"c", byteIn('c'),
+			),
+			match.Seq(TSecond, byteIn('a'), byteIn('x')),
+		),
+	)
+
+	p := parser.New(strings.NewReader("abz"))
+	m, err := grammar.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+
+	assert.Equal(t, TFirst, m.Tag)
+	assert.Equal(t, "ab", string(m.Content))
+	assert.Contains(t, m.Group, "a")
+	assert.Contains(t, m.Group, "b")
+	assert.NotContains(t, m.Group, "c")
+}
+
+func TestBestEffort_Skip(t *testing.T) {
+	t.Parallel()
+
+	TPair := token.NextTag()
+
+	grammar := match.BestEffort(
+		match.Seq(TPair,
+			match.Skip(match.String(token.Literal, "  ")),
+			match.String(token.Literal, "ab"),
+			match.String(token.Literal, "cd"),
+		),
+	)
+
+	p := parser.New(strings.NewReader("  abX"))
+	m, err := grammar.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+
+	assert.Equal(t, match.Partial{}, m.Made)
+	assert.Equal(t, "ab", string(m.Content))
+	assert.Equal(t, 0, m.Start)
+	assert.Equal(t, 4, m.End)
+
+	// the skipped input was consumed too
+	m, err = byteIn('X').Match(p)
+	assert.NoError(t, err)
+	assert.NotNil(t, m)
+}
+
+func TestBestEffort_NoPartial(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("z"))
+	m, err := match.BestEffort(byteIn('a')).Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+}
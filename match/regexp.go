@@ -0,0 +1,114 @@
+package match
+
+import (
+	"errors"
+	"io"
+	"regexp"
+
+	"github.com/zostay/gordy/parser"
+	"github.com/zostay/gordy/token"
+)
+
+// errRuneReader adapts an Input to the io.RuneReader read by the regexp
+// package. The regexp package treats any error as the end of input, so the
+// first error other than the end of input is kept to be reported afterward.
+type errRuneReader struct {
+	p   *parser.Input
+	err error
+}
+
+// ReadRune implements io.RuneReader.
+func (r *errRuneReader) ReadRune() (rune, int, error) {
+	c, n, err := r.p.ReadRune()
+	if err != nil && r.err == nil &&
+		!errors.Is(err, io.EOF) && !errors.Is(err, parser.ErrIncompleteRune) {
+		r.err = err
+	}
+	return c, n, err
+}
+
+// Regexp returns a Matcher that matches the regular expression re anchored at
+// the current position in the input, so "b+" does not match "abb". The
+// returned Match has the given token.Tag and its Content is the matched text.
+// Each named capturing group that took part in the match, such as (?P<year>\d+),
+// is found in the Group of the returned Match under its name with the
+// token.Literal tag. If re does not match, nil is returned and the input is
+// restored.
+//
+// The regular expression reads the input one rune at a time, as far as it needs
+// to decide on a match, so a match may be as long as the buffer allows. An
+// expression that could match arbitrarily far ahead, such as ".*", fails with
+// the error that stopped the read if it runs into the limit of the buffer or
+// the peek window set with parser.Input.SetPeekWindow.
+//
+// The expression is recompiled from re.String() to anchor it, and the regexp
+// package offers no way to ask whether re.Longest was called, so the match is
+// always leftmost-first, as with Perl. Use RegexpLongest for leftmost-longest
+// matching.
+func Regexp(t token.Tag, re *regexp.Regexp) parser.MatcherFunc {
+	return regexpMatcher(t, re, false)
+}
+
+// RegexpLongest returns a Matcher that works just like Regexp, but prefers the
+// leftmost-longest match, as with POSIX. Given "a|ab" and the input "ab",
+// Regexp matches "a" while RegexpLongest matches "ab".
+func RegexpLongest(t token.Tag, re *regexp.Regexp) parser.MatcherFunc {
+	return regexpMatcher(t, re, true)
+}
+
+func regexpMatcher(t token.Tag, re *regexp.Regexp, longest bool) parser.MatcherFunc {
+	anchored := regexp.MustCompile(`^(?:` + re.String() + `)`)
+	if longest {
+		anchored.Longest()
+	}
+	names := anchored.SubexpNames()
+
+	return func(p *parser.Input) (*parser.Match, error) {
+		p.Trace(parser.StageTry, "Regexp", t, re)
+		start := p.Offset()
+
+		rr := &errRuneReader{p: p.MayFail()}
+		idx := anchored.FindReaderSubmatchIndex(rr)
+		if rr.err != nil {
+			p.Trace(parser.StageFail, "Regexp", t, re, rr.err)
+			return nil, rr.err
+		}
+
+		if idx == nil {
+			return nil, nil
+		}
+
+		c := p.MayFail()
+		content := make([]byte, idx[1])
+		if _, err := c.Read(content); err != nil {
+			p.Trace(parser.StageFail, "Regexp", t, re, err)
+			return nil, err
+		}
+		c.Keep()
+
+		m := &parser.Match{
+			Tag:     t,
+			Content: content,
+			Group:   map[string]*parser.Match{},
+			Start:   start,
+			End:     start + idx[1],
+		}
+
+		for i, name := range names {
+			from, to := idx[2*i], idx[2*i+1]
+			if name == "" || from < 0 {
+				continue
+			}
+
+			m.Group[name] = &parser.Match{
+				Tag:     token.Literal,
+				Content: content[from:to],
+				Start:   start + from,
+				End:     start + to,
+			}
+		}
+
+		p.Trace(parser.StageGot, "Regexp", t, re, m)
+		return m, nil
+	}
+}
@@ -0,0 +1,127 @@
+package match_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/gordy/match"
+	"github.com/zostay/gordy/parser"
+	"github.com/zostay/gordy/token"
+)
+
+func TestRegexp(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		re    string
+		input string
+		want  string
+		ok    bool
+	}{
+		{"prefix", `[a-z]+`, "abc123", "abc", true},
+		{"anchored", `b+`, "abb", "", false},
+		{"alternation", `0x[0-9a-f]+|[0-9]+`, "0x1f;", "0x1f", true},
+		{"flags", `(?i)select`, "SELECT *", "SELECT", true},
+		{"empty", `a*`, "bbb", "", true},
+		{"runes", `\p{Greek}+`, "αβγ!", "αβγ", true},
+		{"no match", `[0-9]`, "x", "", false},
+		{"at EOF", `x`, "", "", false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := match.Regexp(token.Literal, regexp.MustCompile(tt.re)).Match(p)
+			assert.NoError(t, err)
+
+			if !tt.ok {
+				assert.Nil(t, m)
+				assert.Equal(t, 0, p.Offset())
+				return
+			}
+
+			require.NotNil(t, m)
+			assert.Equal(t, tt.want, string(m.Content))
+			assert.Equal(t, len(tt.want), p.Offset())
+		})
+	}
+}
+
+func TestRegexpLongest(t *testing.T) {
+	t.Parallel()
+
+	re := regexp.MustCompile(`a|ab`)
+	re.Longest()
+
+	p := parser.New(strings.NewReader("ab"))
+	m, err := match.Regexp(token.Literal, re).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "a", m.Text())
+
+	p = parser.New(strings.NewReader("ab"))
+	m, err = match.RegexpLongest(token.Literal, re).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "ab", m.Text())
+	assert.Equal(t, 2, m.End)
+}
+
+func TestRegexp_Groups(t *testing.T) {
+	t.Parallel()
+
+	date := match.Regexp(token.Literal,
+		regexp.MustCompile(`(?P<year>\d{4})-(?P<month>\d{2})(?:-(?P<day>\d{2}))?`))
+
+	m, err := match.ParseString("x=2023-07", match.Seq(token.Literal,
+		match.String(token.Literal, "x="), date))
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+
+	dm := m.Submatch[1]
+	assert.Equal(t, "2023-07", dm.Text())
+	assert.Equal(t, "2023", dm.Get("year").Text())
+	assert.Equal(t, 2, dm.Get("year").Start)
+	assert.Equal(t, "07", dm.Get("month").Text())
+	assert.Equal(t, 7, dm.Get("month").Start)
+	assert.Equal(t, 9, dm.Get("month").End)
+
+	// a group that did not take part is missing
+	assert.Nil(t, dm.Get("day"))
+}
+
+func TestRegexp_FarAhead(t *testing.T) {
+	t.Parallel()
+
+	// the match is only decided at the closing quote, far from the start
+	str := match.Regexp(token.Literal, regexp.MustCompile(`"[^"]*"`))
+	long := `"` + strings.Repeat("x", 3000) + `"`
+
+	p := parser.New(strings.NewReader(long + " rest"))
+	m, err := str.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, long, string(m.Content))
+
+	// an unterminated string reads to the end and fails
+	p = parser.New(strings.NewReader(long[:2000]))
+	m, err = str.Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+	assert.Equal(t, 0, p.Offset())
+
+	// but reading beyond the peek window is an error
+	p = parser.New(strings.NewReader(long))
+	p.SetPeekWindow(100)
+	m, err = str.Match(p)
+	assert.ErrorIs(t, err, parser.ErrPeekWindow)
+	assert.Nil(t, m)
+}
@@ -0,0 +1,59 @@
+package match
+
+import (
+	"fmt"
+
+	"github.com/zostay/gordy/parser"
+)
+
+// Rule is a Matcher that defers to another Matcher which may be set after the
+// Rule is created. As matchers are built as values, a grammar that refers to
+// itself, directly or through other rules, cannot be built without one. Declare
+// the Rule first, use it in other matchers, and then set its Matcher:
+//
+//	expr := &Rule{Name: "expr"}
+//	term := First(number, Seq(t, open, expr, close))
+//	expr.Matcher = ManyWithSep(t, 1, term, plus)
+//
+// The Matcher is looked up each time the Rule is matched.
+type Rule struct {
+	Name    string         // used in errors and traces
+	Matcher parser.Matcher // the body of the rule
+}
+
+// Match matches the input against the Matcher of the Rule. It returns an error
+// wrapping ErrUndefinedRule if the Matcher has not been set.
+func (r *Rule) Match(p *parser.Input) (*parser.Match, error) {
+	if r.Matcher == nil {
+		err := fmt.Errorf("%w: %s", ErrUndefinedRule, r.Name)
+		p.Trace(parser.StageFail, "Rule", r.Name, err)
+		return nil, err
+	}
+
+	p.Trace(parser.StageTry, "Rule", r.Name)
+	m, err := r.Matcher.Match(p)
+	if err != nil {
+		p.Trace(parser.StageFail, "Rule", r.Name, err)
+		return nil, err
+	}
+
+	p.Trace(parser.StageGot, "Rule", r.Name, m)
+	return m, nil
+}
+
+// Ref returns a Matcher that calls fn to find the Matcher to use each time it
+// is matched. This is another way to build a recursive grammar, where a
+// matcher refers to a variable that is only assigned after the matcher has
+// been built:
+//
+//	var value parser.Matcher
+//	list := Seq(t, open, Many(t, 0, Ref(func() parser.Matcher { return value })), close)
+//	value = First(number, list)
+//
+// The fn is called every time the returned Matcher is matched, so it should be
+// cheap. See Rule for a way to do the same without a function.
+func Ref(fn func() parser.Matcher) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		return fn().Match(p)
+	}
+}
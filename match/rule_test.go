@@ -0,0 +1,116 @@
+package match_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/gordy/match"
+	"github.com/zostay/gordy/parser"
+	"github.com/zostay/gordy/token"
+)
+
+func TestRule(t *testing.T) {
+	t.Parallel()
+
+	expr := &match.Rule{Name: "expr"}
+
+	number := match.Map(
+		match.Many(token.Literal, 1, match.TryAndKeep(digit)),
+		func(m *parser.Match) (interface{}, error) {
+			return strconv.Atoi(string(m.Content))
+		},
+	)
+
+	group := match.Map(
+		match.Seq(token.Literal,
+			match.ByteSlice(token.Literal, []byte("(")),
+			expr,
+			match.ByteSlice(token.Literal, []byte(")")),
+		),
+		func(m *parser.Match) (interface{}, error) {
+			return m.Submatch[1].Made, nil
+		},
+	)
+
+	term := match.First(number, group)
+
+	expr.Matcher = match.Map(
+		match.ManyWithSep(token.Literal, 1, term,
+			match.ByteSlice(token.Literal, []byte("+"))),
+		func(m *parser.Match) (interface{}, error) {
+			sum := 0
+			for _, sm := range m.Submatch {
+				sum += sm.Made.(int)
+			}
+			return sum, nil
+		},
+	)
+
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"1", 1},
+		{"1+2", 3},
+		{"(1+2)", 3},
+		{"1+(2+3)+4", 10},
+		{"((1)+((2+3)))", 6},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := match.Seq(token.Literal, expr, match.EOF()).Match(p)
+			assert.NoError(t, err)
+			require.NotNil(t, m)
+			assert.Equal(t, tt.input, string(m.Content))
+			assert.Equal(t, tt.want, m.Submatch[0].Made)
+		})
+	}
+}
+
+func TestRule_Undefined(t *testing.T) {
+	t.Parallel()
+
+	r := &match.Rule{Name: "missing"}
+
+	p := parser.New(strings.NewReader("x"))
+	m, err := r.Match(p)
+	assert.ErrorIs(t, err, match.ErrUndefinedRule)
+	assert.ErrorContains(t, err, "missing")
+	assert.Nil(t, m)
+}
+
+func TestRef(t *testing.T) {
+	t.Parallel()
+
+	// value := "x" | "[" value* "]"
+	var value parser.Matcher
+	list := match.Seq(token.Literal,
+		match.ByteSlice(token.Literal, []byte("[")),
+		match.Many(token.Literal, 0,
+			match.TryAndKeep(match.Ref(func() parser.Matcher { return value }))),
+		match.ByteSlice(token.Literal, []byte("]")),
+	)
+	value = match.First(match.ByteSlice(token.Literal, []byte("x")), list)
+
+	for _, in := range []string{"x", "[]", "[x[x]]", "[[[]][x]x]"} {
+		m, err := match.ParseString(in, value)
+		assert.NoError(t, err, in)
+		require.NotNil(t, m, in)
+		assert.Equal(t, in, m.Text())
+	}
+
+	for _, in := range []string{"[", "[x", "[[x]"} {
+		m, err := match.ParseString(in, value)
+		assert.NoError(t, err, in)
+		assert.Nil(t, m, in)
+	}
+}
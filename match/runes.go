@@ -1,6 +1,11 @@
 package match
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"unicode"
+
 	"github.com/zostay/go-std/slices"
 
 	"github.com/zostay/gordy/parser"
@@ -79,6 +84,27 @@ func ThisButNotThatRunes(this, that RunePredicate) RunePredicate {
 	}
 }
 
+// RunesInCategory creates a RunePredicate that matches any rune in the named
+// Unicode category or script, such as "L" for letters, "Nd" for decimal digits,
+// or "Han" for Han characters. These are the names used by \p{...} in regular
+// expressions. Categories are looked up in unicode.Categories first and then
+// scripts in unicode.Scripts. If the name is neither, an error wrapping
+// ErrUnknownCategory is returned.
+func RunesInCategory(name string) (RunePredicate, error) {
+	tab, ok := unicode.Categories[name]
+	if !ok {
+		tab, ok = unicode.Scripts[name]
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCategory, name)
+	}
+
+	return func(r rune) bool {
+		return unicode.Is(tab, r)
+	}, nil
+}
+
 // Runes is the Matcher returned by OneRune. It provides a number of tools that
 // allow this Matcher to be combined with other Runes Matchers.
 type Runes struct {
@@ -120,26 +146,15 @@ func NRunes(
 	}
 }
 
-// Match returns a Match with the configured token.Tag if the next byte in the
-// input matches the predicate. It returns nil otherwise.
+// Match returns a Match with the configured token.Tag if the next runes in the
+// input match the predicate at least from times. At most to runes will be
+// matched. It returns nil otherwise and the input is restored.
 func (r *Runes) Match(p *parser.Input) (*parser.Match, error) {
-	rs := make([]rune, r.from, r.from+r.to)
-	for i := 0; i < r.from; i++ {
-		c, ok, err := r.matchOne(p)
-		if err != nil {
-			p.Trace(parser.StageFail, "Runes.Match", r.t, r.from, r.to, r.pred, i, err)
-			return nil, err
-		}
+	p = p.MayFail()
+	start := p.Offset()
 
-		p.Trace(parser.StageTry, "Runes.Match", r.t, r.from, r.to, r.pred, i)
-		if !ok {
-			return nil, nil
-		}
-
-		rs[i] = c
-	}
-
-	for i := r.from; i < r.to; i++ {
+	rs := make([]rune, 0, r.to)
+	for i := 0; i < r.to; i++ {
 		c, ok, err := r.matchOne(p)
 		if err != nil {
 			p.Trace(parser.StageFail, "Runes.Match", r.t, r.from, r.to, r.pred, i, err)
@@ -148,28 +163,44 @@ func (r *Runes) Match(p *parser.Input) (*parser.Match, error) {
 
 		p.Trace(parser.StageTry, "Runes.Match", r.t, r.from, r.to, r.pred, i)
 		if !ok {
+			if i < r.from {
+				return nil, nil
+			}
 			break
 		}
 
 		rs = append(rs, c)
 	}
 
-	m := &parser.Match{Tag: r.t, Content: []byte(string(rs))}
+	end := p.Offset()
+	p.Keep()
+
+	m := &parser.Match{
+		Tag:     r.t,
+		Content: []byte(string(rs)),
+		Start:   start,
+		End:     end,
+	}
 	p.Trace(parser.StageGot, "Runes.Match", r.t, r.from, r.to, r.pred, m)
 	return m, nil
 }
 
 // matchOne returns the matched rune and true or zero and false if no rune was
-// matched.
+// matched. The rune is only consumed if it matches. The end of input, even
+// part way through a rune, is treated as a failure to match.
 func (r *Runes) matchOne(p *parser.Input) (rune, bool, error) {
-	var rs [1]rune
-	_, err := p.ReadRunes(rs[:])
-	if err != nil {
+	p = p.MayFail()
+
+	c, _, err := p.ReadRune()
+	if errors.Is(err, io.EOF) || errors.Is(err, parser.ErrIncompleteRune) {
+		return 0, false, nil
+	} else if err != nil {
 		return 0, false, err
 	}
 
-	if r.pred(rs[0]) {
-		return rs[0], true, nil
+	if r.pred(c) {
+		p.Keep()
+		return c, true, nil
 	}
 
 	return 0, false, nil
@@ -181,24 +212,30 @@ func extractPredFromRunes(r *Runes) RunePredicate {
 
 // AndAlso creates a new Runes Matcher which combines the predicate of this
 // Runes Matcher with predicates of the given Runes Matchers such that a match
-// occurs if the next byte in the input matches any of those predicates. The
+// occurs if the next rune in the input matches any of those predicates. The
 // returned Match (when found), will have the token.Tag of this Runes Matcher.
+// The new Matcher matches the same number of runes as this one.
 func (r *Runes) AndAlso(rs ...*Runes) *Runes {
 	preds := slices.Map(rs, extractPredFromRunes)
-	slices.Unshift(preds, r.pred)
+	preds = slices.Unshift(preds, r.pred)
 	return &Runes{
 		t:    r.t,
+		from: r.from,
+		to:   r.to,
 		pred: AnyRunes(preds...),
 	}
 }
 
-// ButNot creates a new Bytes Matcher which combines the predicate of this
-// Bytes Matcher with predicates of the given Bytes Matchers such that a match
-// is successful if it matches this Bytes Matcher, but not those.
+// ButNot creates a new Runes Matcher which combines the predicate of this
+// Runes Matcher with predicates of the given Runes Matchers such that a match
+// is successful if it matches this Runes Matcher, but not those. The new Matcher
+// matches the same number of runes as this one.
 func (r *Runes) ButNot(rs ...*Runes) *Runes {
 	preds := slices.Map(rs, extractPredFromRunes)
 	return &Runes{
 		t:    r.t,
+		from: r.from,
+		to:   r.to,
 		pred: ThisButNotThatRunes(r.pred, AnyRunes(preds...)),
 	}
 }
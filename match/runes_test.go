@@ -0,0 +1,168 @@
+package match_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/gordy/match"
+	"github.com/zostay/gordy/parser"
+	"github.com/zostay/gordy/token"
+)
+
+func TestNRunes_Range(t *testing.T) {
+	t.Parallel()
+
+	greek := match.RunesInRange('α', 'ω')
+
+	tests := []struct {
+		name     string
+		from, to int
+		input    string
+		want     string
+		matched  bool
+	}{
+		{"from==to exact", 2, 2, "αβγ", "αβ", true},
+		{"from==to short", 2, 2, "αx", "", false},
+		{"from<to max", 1, 3, "αβγδε", "αβγ", true},
+		{"from<to between", 1, 3, "αβx", "αβ", true},
+		{"from<to short", 2, 3, "αx", "", false},
+		{"from==0 none", 0, 2, "x", "", true},
+		{"from==0 some", 0, 2, "αx", "α", true},
+		{"from==0 at end", 0, 2, "", "", true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := match.NRunes(token.Literal, tt.from, tt.to, greek).Match(p)
+			assert.NoError(t, err)
+			if !tt.matched {
+				assert.Nil(t, m)
+				return
+			}
+
+			require.NotNil(t, m)
+			assert.Equal(t, tt.want, string(m.Content))
+		})
+	}
+}
+
+func TestOneRune(t *testing.T) {
+	t.Parallel()
+
+	alpha := match.OneRune(token.Literal, match.RunesInSet('α'))
+
+	p := parser.New(strings.NewReader("αx"))
+	m, err := alpha.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "α", string(m.Content))
+
+	// fails on x without consuming it
+	m, err = alpha.Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	m, err = match.OneRune(token.Literal, match.RunesInSet('x')).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+
+	// fails at the end of input
+	m, err = alpha.Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestRunesInCategory(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		match string
+		miss  string
+	}{
+		{"L", "aZé", "1 -"},
+		{"Han", "漢字", "aカ"},
+		{"Nd", "7٣", "aⅦ"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			pred, err := match.RunesInCategory(tt.name)
+			require.NoError(t, err)
+			one := match.OneRune(token.Literal, pred)
+
+			for _, r := range tt.match {
+				m, err := one.Match(parser.New(strings.NewReader(string(r))))
+				assert.NoError(t, err)
+				assert.NotNil(t, m, "%q matches", r)
+			}
+
+			for _, r := range tt.miss {
+				m, err := one.Match(parser.New(strings.NewReader(string(r))))
+				assert.NoError(t, err)
+				assert.Nil(t, m, "%q does not match", r)
+			}
+		})
+	}
+
+	pred, err := match.RunesInCategory("Nope")
+	assert.Nil(t, pred)
+	assert.ErrorIs(t, err, match.ErrUnknownCategory)
+}
+
+func BenchmarkNRunes(b *testing.B) {
+	in := strings.Repeat("é", 1000)
+	run := match.NRunes(token.Literal, 0, 1000, match.RunesInSet('é'))
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := parser.New(strings.NewReader(in))
+		if m, err := run.Match(p); err != nil || m.Length() != len(in) {
+			b.Fatal("failed to match", err)
+		}
+	}
+}
+
+func TestRunes_CombineKeepsRange(t *testing.T) {
+	t.Parallel()
+
+	two := match.NRunes(token.Literal, 2, 2, match.RunesInRange('α', 'ω')).(*match.Runes)
+	latin := match.OneRune(token.Literal, match.RunesInRange('a', 'z')).(*match.Runes)
+	omega := match.OneRune(token.Literal, match.RunesInSet('ω')).(*match.Runes)
+
+	m, err := two.AndAlso(latin).Match(parser.New(strings.NewReader("αbγ")))
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "αb", string(m.Content))
+
+	m, err = two.ButNot(omega).Match(parser.New(strings.NewReader("αβγ")))
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "αβ", string(m.Content))
+
+	m, err = two.ButNot(omega).Match(parser.New(strings.NewReader("αω")))
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestOneRune_IncompleteAtEOF(t *testing.T) {
+	t.Parallel()
+
+	anyRune := match.OneRune(token.Literal, func(rune) bool { return true })
+
+	p := parser.New(strings.NewReader("€"[:2]))
+	m, err := anyRune.Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+	assert.Equal(t, 0, p.Offset())
+}
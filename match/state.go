@@ -0,0 +1,185 @@
+package match
+
+import (
+	"fmt"
+
+	"github.com/zostay/gordy/parser"
+	"github.com/zostay/gordy/token"
+)
+
+// stateKey is used to create unique keys for storing matcher state on a
+// parser.Input.
+type stateKey struct {
+	name string
+}
+
+// Scoped returns a Matcher that runs the given Matcher with fresh state (see
+// parser.Input.Scope). State set while matching, such as the keys remembered
+// by UniqueBy, is forgotten once the Matcher returns. Use this to limit a
+// uniqueness constraint to a single structure, such as the members of one
+// object.
+func Scoped(mtch parser.Matcher) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		p = p.Scope()
+
+		m, err := mtch.Match(p)
+		if err != nil || m == nil {
+			return nil, err
+		}
+
+		p.Keep()
+		return m, nil
+	}
+}
+
+// UniqueBy returns a Matcher that calls the given Matcher and uses the key
+// function to extract a key from each Match. It is intended to be used inside
+// a repetition, such as Many. If the key has already been seen during the
+// parse, an error wrapping ErrDuplicate is returned. The keys are kept as state
+// on the parser.Input, so keys matched on input that is later discarded are
+// forgotten. Wrap the enclosing structure with Scoped to keep separate sets of
+// keys for separate structures.
+func UniqueBy(
+	mtch parser.Matcher,
+	key func(*parser.Match) string,
+) parser.MatcherFunc {
+	sk := &stateKey{"UniqueBy"}
+	return func(p *parser.Input) (*parser.Match, error) {
+		m, err := mtch.Match(p)
+		if err != nil || m == nil {
+			return nil, err
+		}
+
+		k := key(m)
+		seen, _ := p.Value(sk).(map[string]struct{})
+		if _, dup := seen[k]; dup {
+			err := fmt.Errorf("%w: %q", ErrDuplicate, k)
+			p.Trace(parser.StageFail, "UniqueBy", mtch, err)
+			return nil, err
+		}
+
+		// copy on write so the set is restored if this input is discarded
+		next := make(map[string]struct{}, len(seen)+1)
+		for s := range seen {
+			next[s] = struct{}{}
+		}
+		next[k] = struct{}{}
+		p.SetValue(sk, next)
+
+		return m, nil
+	}
+}
+
+// onceKey is used to store the state of Once on a parser.Input.
+type onceKey string
+
+// Once returns a Matcher that calls the given Matcher, which may only match
+// once during a parse. The first Match is remembered as state on the
+// parser.Input under the given key. If a Matcher using the same key matches
+// again, an error wrapping ErrDuplicate is returned. As with UniqueBy, a Match
+// made on input that is later discarded is forgotten.
+func Once(key string, mtch parser.Matcher) parser.MatcherFunc {
+	sk := onceKey(key)
+	return func(p *parser.Input) (*parser.Match, error) {
+		m, err := mtch.Match(p)
+		if err != nil || m == nil {
+			return nil, err
+		}
+
+		if p.Value(sk) != nil {
+			err := fmt.Errorf("%w: %s may only appear once", ErrDuplicate, key)
+			p.Trace(parser.StageFail, "Once", key, mtch, err)
+			return nil, err
+		}
+
+		p.SetValue(sk, m)
+		return m, nil
+	}
+}
+
+// tagStack is an immutable stack of open tag names. Each push creates a new
+// head, so a stack stored on an input that is later discarded is forgotten
+// along with it.
+type tagStack struct {
+	name string
+	next *tagStack
+}
+
+// tagBalanceKey is the key used to store the tagStack on a parser.Input.
+var tagBalanceKey = &stateKey{"TagBalance"}
+
+// OpenTag returns a Matcher that calls the given Matcher to match the name of an
+// opening tag. On a match, the Content of the name is pushed onto a stack of
+// open tags kept as state on the parser.Input and the name Match is returned.
+// A later CloseTag must match the same name.
+//
+// The grammar decides what an open tag looks like, OpenTag only tracks the
+// name. For example:
+//
+//	open := Seq(tag, String(t, "<"), OpenTag(name), String(t, ">"))
+//	close := Seq(tag, String(t, "</"), CloseTag(name), String(t, ">"))
+func OpenTag(name parser.Matcher) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		m, err := name.Match(p)
+		if err != nil || m == nil {
+			return nil, err
+		}
+
+		open, _ := p.Value(tagBalanceKey).(*tagStack)
+		p.SetValue(tagBalanceKey, &tagStack{string(m.Content), open})
+
+		return m, nil
+	}
+}
+
+// CloseTag returns a Matcher that calls the given Matcher to match the name of
+// a closing tag. On a match, the name is compared to the most recent tag
+// opened with OpenTag. If they are the same, that tag is popped from the stack
+// and the name Match is returned. If they differ or no tag is open, an error
+// wrapping ErrUnbalanced is returned.
+func CloseTag(name parser.Matcher) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		m, err := name.Match(p)
+		if err != nil || m == nil {
+			return nil, err
+		}
+
+		open, _ := p.Value(tagBalanceKey).(*tagStack)
+		if open == nil {
+			err := fmt.Errorf("%w: %q closed but never opened",
+				ErrUnbalanced, m.Content)
+			p.Trace(parser.StageFail, "CloseTag", name, err)
+			return nil, err
+		}
+
+		if open.name != string(m.Content) {
+			err := fmt.Errorf("%w: %q closed while %q is open",
+				ErrUnbalanced, m.Content, open.name)
+			p.Trace(parser.StageFail, "CloseTag", name, err)
+			return nil, err
+		}
+
+		p.SetValue(tagBalanceKey, open.next)
+
+		return m, nil
+	}
+}
+
+// TagBalance returns a Matcher that checks that every tag opened with OpenTag
+// has been closed with CloseTag. If so, it returns an empty Match with the
+// token.None tag. Otherwise, it returns an error wrapping ErrUnbalanced naming
+// the innermost open tag. No input is consumed. Use it at the end of the
+// document, for example in a Seq before EOF.
+func TagBalance() parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		open, _ := p.Value(tagBalanceKey).(*tagStack)
+		if open != nil {
+			err := fmt.Errorf("%w: %q is never closed", ErrUnbalanced, open.name)
+			p.Trace(parser.StageFail, "TagBalance", err)
+			return nil, err
+		}
+
+		at := p.Offset()
+		return &parser.Match{Tag: token.None, Start: at, End: at}, nil
+	}
+}
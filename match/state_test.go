@@ -0,0 +1,167 @@
+package match_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/gordy/match"
+	"github.com/zostay/gordy/parser"
+	"github.com/zostay/gordy/token"
+)
+
+func contentKey(m *parser.Match) string {
+	return string(m.Content)
+}
+
+func TestUniqueBy(t *testing.T) {
+	t.Parallel()
+
+	letter := byteIn([]byte("abcdefghijklmnopqrstuvwxyz")...)
+	letters := match.Many(token.Literal, 1,
+		match.TryAndKeep(match.UniqueBy(letter, contentKey)))
+
+	p := parser.New(strings.NewReader("abc"))
+	m, err := letters.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "abc", string(m.Content))
+
+	p = parser.New(strings.NewReader("abca"))
+	m, err = letters.Match(p)
+	assert.ErrorIs(t, err, match.ErrDuplicate)
+	assert.ErrorContains(t, err, `"a"`)
+	assert.Nil(t, m)
+}
+
+func TestUniqueBy_Backtrack(t *testing.T) {
+	t.Parallel()
+
+	a := match.UniqueBy(byteIn('a'), contentKey)
+	either := match.First(
+		match.Seq(token.Literal, a, byteIn('x')),
+		match.Seq(token.Literal, a, byteIn('y')),
+	)
+
+	p := parser.New(strings.NewReader("ay"))
+	m, err := either.Match(p)
+	assert.NoError(t, err)
+	assert.NotNil(t, m)
+}
+
+func TestScoped(t *testing.T) {
+	t.Parallel()
+
+	letter := byteIn([]byte("abcdefghijklmnopqrstuvwxyz")...)
+	group := match.Scoped(match.Many(token.Literal, 1,
+		match.TryAndKeep(match.UniqueBy(letter, contentKey))))
+	groups := match.ManyWithSep(token.Literal, 1,
+		group,
+		match.TryAndKeep(byteIn(',')),
+	)
+
+	p := parser.New(strings.NewReader("ab,ba"))
+	m, err := groups.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "ab,ba", string(m.Content))
+
+	p = parser.New(strings.NewReader("ab,bab"))
+	m, err = groups.Match(p)
+	assert.ErrorIs(t, err, match.ErrDuplicate)
+	assert.Nil(t, m)
+}
+
+func TestOnce(t *testing.T) {
+	t.Parallel()
+
+	version := match.Once("version", byteIn('v'))
+	directives := match.Many(token.Literal, 0,
+		match.First(version, byteIn('x')))
+
+	p := parser.New(strings.NewReader("xvx"))
+	m, err := directives.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "xvx", string(m.Content))
+
+	p = parser.New(strings.NewReader("vxv"))
+	m, err = directives.Match(p)
+	assert.ErrorIs(t, err, match.ErrDuplicate)
+	assert.ErrorContains(t, err, "version")
+	assert.Nil(t, m)
+}
+
+func TestOnce_Backtrack(t *testing.T) {
+	t.Parallel()
+
+	version := match.Once("version", byteIn('v'))
+	either := match.First(
+		match.Seq(token.Literal, version, byteIn('a')),
+		match.Seq(token.Literal, version, byteIn('b')),
+	)
+
+	p := parser.New(strings.NewReader("vb"))
+	m, err := either.Match(p)
+	assert.NoError(t, err)
+	assert.NotNil(t, m)
+}
+
+func tagDocument() parser.Matcher {
+	name := match.Many(token.Literal, 1,
+		match.TryAndKeep(byteIn([]byte("abcdefghijklmnopqrstuvwxyz")...)))
+	open := match.Seq(token.Literal,
+		match.ByteSlice(token.Literal, []byte("<")),
+		match.OpenTag(name),
+		match.ByteSlice(token.Literal, []byte(">")),
+	)
+	closing := match.Seq(token.Literal,
+		match.ByteSlice(token.Literal, []byte("</")),
+		match.CloseTag(name),
+		match.ByteSlice(token.Literal, []byte(">")),
+	)
+
+	return match.Seq(token.Literal,
+		match.Many(token.Literal, 0,
+			match.First(match.TryAndKeep(closing), match.TryAndKeep(open))),
+		match.TagBalance(),
+		match.EOF(),
+	)
+}
+
+func TestTagBalance(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"<a><b></b></a>", ""},
+		{"<a></a><b></b>", ""},
+		{"<a><b></a></b>", `"a" closed while "b" is open`},
+		{"</a>", `"a" closed but never opened`},
+		{"<a><b></b>", `"a" is never closed`},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := tagDocument().Match(p)
+			if tt.err == "" {
+				assert.NoError(t, err)
+				require.NotNil(t, m)
+				assert.Equal(t, tt.input, string(m.Content))
+				return
+			}
+
+			assert.ErrorIs(t, err, match.ErrUnbalanced)
+			assert.ErrorContains(t, err, tt.err)
+			assert.Nil(t, m)
+		})
+	}
+}
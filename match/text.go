@@ -0,0 +1,361 @@
+package match
+
+import (
+	"errors"
+	"io"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/zostay/gordy/parser"
+	"github.com/zostay/gordy/token"
+)
+
+// isPrintable is the predicate used by PrintableText. It accepts any rune that
+// is not a C0 or C1 control character, except for tab.
+func isPrintable(r rune) bool {
+	return r == '\t' || !unicode.IsControl(r)
+}
+
+// PrintableText returns a Matcher that matches a run of printable UTF-8 runes.
+// The run stops at the first control character (C0 or C1, but tab is allowed)
+// or invalid UTF-8 encoding, which is not consumed. If fewer than min runes are
+// matched, then nil is returned.
+func PrintableText(
+	t token.Tag,
+	min int,
+) parser.Matcher {
+	return parser.MatcherFunc(func(p *parser.Input) (*parser.Match, error) {
+		p.Trace(parser.StageTry, "PrintableText", t, min)
+		start := p.Offset()
+
+		content := make([]byte, 0)
+		count := 0
+		for {
+			c := p.MayFail()
+
+			var rs [1]rune
+			n, err := c.ReadRunes(rs[:])
+			if errors.Is(err, io.EOF) || errors.Is(err, parser.ErrIncompleteRune) {
+				break
+			} else if err != nil {
+				p.Trace(parser.StageFail, "PrintableText", t, min, err)
+				return nil, err
+			}
+
+			if rs[0] == utf8.RuneError && n == 1 {
+				break
+			}
+
+			if !isPrintable(rs[0]) {
+				break
+			}
+
+			c.Keep()
+			content = utf8.AppendRune(content, rs[0])
+			count++
+		}
+
+		if count < min {
+			return nil, nil
+		}
+
+		m := &parser.Match{Tag: t, Content: content, Start: start, End: p.Offset()}
+		p.Trace(parser.StageGot, "PrintableText", t, min, m)
+		return m, nil
+	})
+}
+
+// Unparsed is stored in the Made field of the Match returned by OrRestOfLine
+// when the Matcher given failed and the rest of the line was taken instead. It
+// holds the text of the rest of the line.
+type Unparsed string
+
+// isEndOfLine is a BytePredicate matching the bytes that end a line.
+var isEndOfLine = BytesInSet('\r', '\n')
+
+// OrRestOfLine returns a Matcher that returns the Match of the given Matcher if
+// it matches. Otherwise, it matches the rest of the line, up to but not
+// including the next carriage return or newline, and returns that as a Match
+// with the given token.Tag and an Unparsed value in Made. This allows a line
+// oriented grammar to collect the lines it cannot parse and keep going. It
+// returns nil only when at the end of input.
+func OrRestOfLine(
+	t token.Tag,
+	mtch parser.Matcher,
+) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		m, err := TryAndKeep(mtch).Match(p)
+		if err != nil || m != nil {
+			return m, err
+		}
+
+		start := p.Offset()
+		content := make([]byte, 0)
+		atEOF := true
+		for {
+			c := p.MayFail()
+
+			var bs [1]byte
+			_, err := c.Read(bs[:])
+			if errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				p.Trace(parser.StageFail, "OrRestOfLine", t, mtch, err)
+				return nil, err
+			}
+
+			atEOF = false
+			if isEndOfLine(bs[0]) {
+				break
+			}
+
+			c.Keep()
+			content = append(content, bs[0])
+		}
+
+		if atEOF {
+			return nil, nil
+		}
+
+		m = &parser.Match{
+			Tag:     t,
+			Content: content,
+			Made:    Unparsed(content),
+			Start:   start,
+			End:     p.Offset(),
+		}
+
+		p.Trace(parser.StageGot, "OrRestOfLine", t, mtch, m)
+		return m, nil
+	}
+}
+
+// fold matches a line break followed by whitespace, which continues a folded
+// line.
+var fold = Seq(token.Literal,
+	Optional(OneByte(token.Literal, BytesInSet('\r'))),
+	OneByte(token.Literal, BytesInSet('\n')),
+	Many(token.Literal, 1, OneByte(token.Literal, BytesInSet(' ', '\t'))),
+)
+
+// FoldedValue returns a Matcher that matches a header value as found in email
+// and HTTP messages, where a long value may be folded across several lines by
+// starting each continuation line with whitespace. Lines may end in either
+// CRLF or LF. The Content of the returned Match is the raw value, including
+// the folds, and Made is the unfolded value as a string, with each line break
+// and the whitespace following it replaced with a single space. The line break
+// ending the value is not consumed.
+func FoldedValue(t token.Tag) parser.MatcherFunc {
+	notEndOfLine := NotBytes(isEndOfLine)
+	return func(p *parser.Input) (*parser.Match, error) {
+		start := p.Offset()
+		raw := make([]byte, 0)
+		unfolded := make([]byte, 0)
+		for {
+			line, err := readWhile(p, notEndOfLine)
+			if err != nil {
+				p.Trace(parser.StageFail, "FoldedValue", t, err)
+				return nil, err
+			}
+
+			raw = append(raw, line...)
+			unfolded = append(unfolded, line...)
+
+			m, err := TryAndKeep(fold).Match(p)
+			if err != nil {
+				p.Trace(parser.StageFail, "FoldedValue", t, err)
+				return nil, err
+			}
+
+			if m == nil {
+				break
+			}
+
+			raw = append(raw, m.Content...)
+			unfolded = append(unfolded, ' ')
+		}
+
+		m := &parser.Match{
+			Tag:     t,
+			Content: raw,
+			Made:    string(unfolded),
+			Start:   start,
+			End:     p.Offset(),
+		}
+		p.Trace(parser.StageGot, "FoldedValue", t, m)
+		return m, nil
+	}
+}
+
+// unescape returns the byte represented by the given byte following a
+// backslash in a quoted string.
+func unescape(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	default:
+		return c
+	}
+}
+
+// quoted matches a double quoted string with backslash escapes and returns
+// the decoded value.
+func quoted(p *parser.Input) (raw []byte, value []byte, err error) {
+	var bs [1]byte
+	read := func() (bool, error) {
+		_, err := p.Read(bs[:])
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	ok, err := read()
+	if !ok || bs[0] != '"' {
+		return nil, nil, err
+	}
+
+	raw = []byte{'"'}
+	value = make([]byte, 0)
+	for {
+		if ok, err := read(); !ok {
+			return nil, nil, err
+		}
+
+		raw = append(raw, bs[0])
+		switch bs[0] {
+		case '"':
+			return raw, value, nil
+		case '\\':
+			if ok, err := read(); !ok {
+				return nil, nil, err
+			}
+
+			raw = append(raw, bs[0])
+			value = append(value, unescape(bs[0]))
+		default:
+			value = append(value, bs[0])
+		}
+	}
+}
+
+// QuotedOrBare returns a Matcher that matches a value that may be given either
+// as a double quoted string or as a bare word, as is common in configuration
+// files. If the input starts with a double quote, a quoted string is matched,
+// in which a backslash escapes the next byte (with \n, \r, and \t standing for
+// newline, carriage return, and tab). Otherwise, a bare value of at least one
+// byte is matched, which runs up to the first byte matching bareStop. Either
+// way, the Content of the returned Match is the raw input and Made is the
+// decoded value as a string. If an opening quote is never closed, nil is
+// returned and the input is restored.
+func QuotedOrBare(
+	t token.Tag,
+	bareStop BytePredicate,
+) parser.MatcherFunc {
+	bare := NotBytes(bareStop, BytesInSet('"'))
+	return func(p *parser.Input) (*parser.Match, error) {
+		start := p.Offset()
+		c := p.MayFail()
+		raw, value, err := quoted(c)
+		if err != nil {
+			p.Trace(parser.StageFail, "QuotedOrBare", t, bareStop, err)
+			return nil, err
+		}
+
+		if raw != nil {
+			c.Keep()
+			m := &parser.Match{
+				Tag:     t,
+				Content: raw,
+				Made:    string(value),
+				Start:   start,
+				End:     p.Offset(),
+			}
+			p.Trace(parser.StageGot, "QuotedOrBare", t, bareStop, m)
+			return m, nil
+		}
+
+		raw, err = readWhile(p, bare)
+		if err != nil {
+			p.Trace(parser.StageFail, "QuotedOrBare", t, bareStop, err)
+			return nil, err
+		}
+
+		if len(raw) == 0 {
+			return nil, nil
+		}
+
+		m := &parser.Match{
+			Tag:     t,
+			Content: raw,
+			Made:    string(raw),
+			Start:   start,
+			End:     p.Offset(),
+		}
+		p.Trace(parser.StageGot, "QuotedOrBare", t, bareStop, m)
+		return m, nil
+	}
+}
+
+// Normalized returns a Matcher that runs the given Matcher and stores the
+// Unicode normalized form of its Content as a string in Made. The Content is
+// left as it was read. This allows text such as identifiers or filenames to be
+// compared by Made regardless of how accented characters were composed.
+func Normalized(form norm.Form, mtch parser.Matcher) parser.MatcherFunc {
+	return Map(mtch, func(m *parser.Match) (interface{}, error) {
+		return form.String(string(m.Content)), nil
+	})
+}
+
+// Lexeme returns a Matcher that matches the given Matcher and then skips any
+// whitespace that follows, as matched by ws. The returned Match is the Match
+// of mtch, so the whitespace is not part of its Content. If ws does not match,
+// nothing is skipped. This is the usual lexer pattern where every token
+// consumes the whitespace after it.
+func Lexeme(mtch, ws parser.Matcher) parser.MatcherFunc {
+	return func(p *parser.Input) (*parser.Match, error) {
+		p = p.MayFail()
+
+		m, err := mtch.Match(p)
+		if err != nil || m == nil {
+			return nil, err
+		}
+
+		if _, err := TryAndKeep(ws).Match(p); err != nil {
+			p.Trace(parser.StageFail, "Lexeme", mtch, ws, err)
+			return nil, err
+		}
+
+		p.Keep()
+		return m, nil
+	}
+}
+
+// Token returns a Matcher that matches the literal string s, such as a keyword
+// or operator, followed by any whitespace matched by ws. It works like Lexeme
+// using String. For most grammars, ws is OptWhitespace().
+func Token(t token.Tag, s string, ws parser.Matcher) parser.MatcherFunc {
+	return Lexeme(String(t, s), ws)
+}
+
+// isWhitespace is the predicate used by Whitespace and OptWhitespace.
+var isWhitespace = BytesInSet(' ', '\t', '\r', '\n')
+
+// Whitespace returns a Matcher that matches one or more spaces, tabs, carriage
+// returns, or line feeds. If there is no whitespace, it returns nil.
+func Whitespace(t token.Tag) parser.Matcher {
+	return TakeWhile1(t, isWhitespace)
+}
+
+// OptWhitespace returns a Matcher that matches zero or more spaces, tabs,
+// carriage returns, or line feeds, so it always matches. The Match has the
+// token.None tag. It is the usual ws to give to Lexeme and Token.
+func OptWhitespace() parser.Matcher {
+	return TakeWhile(token.None, isWhitespace)
+}
@@ -0,0 +1,302 @@
+package match_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/zostay/gordy/match"
+	"github.com/zostay/gordy/parser"
+	"github.com/zostay/gordy/token"
+)
+
+func TestPrintableText(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		min   int
+		want  string
+		fail  bool
+	}{
+		{"plain", "hello", 1, "hello", false},
+		{"unicode", "héllo wörld", 1, "héllo wörld", false},
+		{"tab", "a\tb", 1, "a\tb", false},
+		{"stops at C0", "ab\x01cd", 1, "ab", false},
+		{"stops at newline", "ab\ncd", 1, "ab", false},
+		{"stops at C1", "ab\u0085cd", 1, "ab", false},
+		{"stops at DEL", "ab\x7fcd", 1, "ab", false},
+		{"stops at invalid UTF-8", "ab\xffcd", 1, "ab", false},
+		{"empty allowed", "\x01", 0, "", false},
+		{"too short", "ab\x01", 3, "", true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := match.PrintableText(token.Literal, tt.min).Match(p)
+			assert.NoError(t, err)
+			if tt.fail {
+				assert.Nil(t, m)
+				return
+			}
+
+			require.NotNil(t, m)
+			assert.Equal(t, tt.want, string(m.Content))
+		})
+	}
+}
+
+func TestPrintableText_LeavesControl(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("ab\x01"))
+	m, err := match.PrintableText(token.Literal, 1).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+
+	m, err = byteIn('\x01').Match(p)
+	assert.NoError(t, err)
+	assert.NotNil(t, m)
+}
+
+func TestOrRestOfLine(t *testing.T) {
+	t.Parallel()
+
+	TUnknown := token.NextTag()
+
+	digits := match.NBytes(token.Literal, 1, 10, match.BytesInRange('0', '9'))
+	newline := match.OneByte(token.Literal, match.BytesInSet('\n'))
+	line := match.OrRestOfLine(TUnknown, digits)
+	lines := match.ManyWithSep(token.Literal, 1, line, newline)
+
+	p := parser.New(strings.NewReader("123\nabc 456\n\n789"))
+	m, err := lines.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	require.Len(t, m.Submatch, 4)
+
+	assert.Equal(t, token.Literal, m.Submatch[0].Tag)
+	assert.Equal(t, "123", string(m.Submatch[0].Content))
+	assert.Nil(t, m.Submatch[0].Made)
+
+	assert.Equal(t, TUnknown, m.Submatch[1].Tag)
+	assert.Equal(t, "abc 456", string(m.Submatch[1].Content))
+	assert.Equal(t, match.Unparsed("abc 456"), m.Submatch[1].Made)
+
+	assert.Equal(t, TUnknown, m.Submatch[2].Tag)
+	assert.Equal(t, "", string(m.Submatch[2].Content))
+	assert.Equal(t, match.Unparsed(""), m.Submatch[2].Made)
+
+	assert.Equal(t, "789", string(m.Submatch[3].Content))
+
+	m, err = line.Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestFoldedValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		input    string
+		raw      string
+		unfolded string
+		rest     string
+	}{
+		{"single line", "hello\r\nNext", "hello", "hello", "\r"},
+		{"CRLF folds", "hello\r\n world\r\n\tagain\r\nNext",
+			"hello\r\n world\r\n\tagain", "hello world again", "\r"},
+		{"LF folds", "hello\n world\nNext", "hello\n world", "hello world", "\n"},
+		{"long whitespace", "a\r\n \t  b\r\n", "a\r\n \t  b", "a b", "\r"},
+		{"empty", "\r\nNext", "", "", "\r"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := match.FoldedValue(token.Literal).Match(p)
+			assert.NoError(t, err)
+			require.NotNil(t, m)
+			assert.Equal(t, tt.raw, string(m.Content))
+			assert.Equal(t, tt.unfolded, m.Made)
+
+			var bs [1]byte
+			_, err = p.Read(bs[:])
+			assert.NoError(t, err)
+			assert.Equal(t, tt.rest, string(bs[:]))
+		})
+	}
+}
+
+func TestQuotedOrBare(t *testing.T) {
+	t.Parallel()
+
+	stop := match.BytesInSet(' ', '\t', '\n', '#')
+
+	tests := []struct {
+		name  string
+		input string
+		raw   string
+		value string
+		rest  string
+		fail  bool
+	}{
+		{"bare", "value # comment", "value", "value", " ", false},
+		{"bare to end", "value", "value", "value", "", false},
+		{"quoted", `"two words" x`, `"two words"`, "two words", " ", false},
+		{"escapes", `"a \"b\" \\ c\n"`, `"a \"b\" \\ c\n"`, "a \"b\" \\ c\n", "", false},
+		{"empty quoted", `""`, `""`, "", "", false},
+		{"unterminated", `"abc`, "", "", `"`, true},
+		{"empty bare", " value", "", "", " ", true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+			m, err := match.QuotedOrBare(token.Literal, stop).Match(p)
+			assert.NoError(t, err)
+			if tt.fail {
+				assert.Nil(t, m)
+			} else {
+				require.NotNil(t, m)
+				assert.Equal(t, tt.raw, string(m.Content))
+				assert.Equal(t, tt.value, m.Made)
+			}
+
+			var bs [1]byte
+			n, _ := p.Read(bs[:])
+			assert.Equal(t, tt.rest, string(bs[:n]))
+		})
+	}
+}
+
+func TestNormalized(t *testing.T) {
+	t.Parallel()
+
+	const (
+		composed   = "caf\u00e9"
+		decomposed = "cafe\u0301"
+	)
+
+	word := match.NRunes(token.Literal, 1, 20,
+		match.RunesInRange('a', 'z'), match.RunesInRange('\u00c0', '\u036f'))
+
+	for _, in := range []string{composed, decomposed} {
+		p := parser.New(strings.NewReader(in))
+		m, err := match.Normalized(norm.NFC, word).Match(p)
+		assert.NoError(t, err)
+		require.NotNil(t, m)
+		assert.Equal(t, in, string(m.Content))
+		assert.Equal(t, composed, m.Made)
+
+		p = parser.New(strings.NewReader(in))
+		m, err = match.Normalized(norm.NFD, word).Match(p)
+		assert.NoError(t, err)
+		require.NotNil(t, m)
+		assert.Equal(t, decomposed, m.Made)
+	}
+}
+
+func TestLexeme(t *testing.T) {
+	t.Parallel()
+
+	ws := match.TakeWhile(token.Literal, match.BytesInSet(' ', '\t'))
+	word := match.Lexeme(
+		match.TakeWhile1(token.Literal, match.BytesInRange('a', 'z')), ws)
+
+	m, err := match.ParseString("foo   bar",
+		match.Seq(token.Literal, word, word))
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	require.Len(t, m.Submatch, 2)
+	assert.Equal(t, "foo", m.Submatch[0].Text())
+	assert.Equal(t, "bar", m.Submatch[1].Text())
+
+	// the whitespace is skipped, but not part of any content
+	assert.Equal(t, "foobar", m.Text())
+}
+
+func TestToken(t *testing.T) {
+	t.Parallel()
+
+	ws := match.TakeWhile(token.Literal, match.BytesInSet(' '))
+	stmt := match.Seq(token.Literal,
+		match.Token(token.Literal, "let", ws),
+		match.Lexeme(match.TakeWhile1(token.Literal, match.BytesInRange('a', 'z')), ws),
+		match.Token(token.Literal, "=", ws),
+		match.TakeWhile1(token.Literal, match.BytesInRange('0', '9')),
+	)
+
+	m, err := match.ParseString("let x  = 42", stmt)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "let", m.Submatch[0].Text())
+	assert.Equal(t, "x", m.Submatch[1].Text())
+	assert.Equal(t, "=", m.Submatch[2].Text())
+	assert.Equal(t, "42", m.Submatch[3].Text())
+
+	m, err = match.ParseString("lex x = 1", stmt)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestWhitespace(t *testing.T) {
+	t.Parallel()
+
+	m, err := match.ParseString(" \t\r\n", match.Whitespace(token.Literal))
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, " \t\r\n", m.Text())
+
+	// at least one is required
+	p := parser.New(strings.NewReader("x"))
+	m, err = match.Whitespace(token.Literal).Match(p)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	m, err = match.OptWhitespace().Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, token.None, m.Tag)
+	assert.Empty(t, m.Content)
+}
+
+func TestToken_OptWhitespace(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("if \t\n (x)"))
+	m, err := match.Token(token.Literal, "if", match.OptWhitespace()).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "if", m.Text())
+
+	// the cursor is just past the trailing whitespace
+	assert.Equal(t, 6, p.Offset())
+
+	var bs [1]byte
+	_, err = p.Read(bs[:])
+	assert.NoError(t, err)
+	assert.Equal(t, "(", string(bs[:]))
+
+	// no trailing whitespace is fine too
+	p = parser.New(strings.NewReader("if("))
+	m, err = match.Token(token.Literal, "if", match.OptWhitespace()).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, 2, p.Offset())
+}
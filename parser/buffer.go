@@ -3,19 +3,102 @@ package parser
 import (
 	"bufio"
 	"errors"
+	"fmt"
 	"io"
 	"sync"
 	"unicode/utf8"
 )
 
-type batch struct {
-	closed bool
+// ErrPeekWindow is returned (wrapped) when a read would look further ahead of
+// the kept input than the peek window set with Input.SetPeekWindow.
+var ErrPeekWindow = errors.New("read beyond peek window")
+
+// ErrIncompleteRune is returned (wrapped) when reading runes and the input ends
+// part way through the UTF-8 encoding of a rune. Invalid encodings that are not
+// cut short by the end of input are read as utf8.RuneError instead.
+var ErrIncompleteRune = errors.New("incomplete rune at end of input")
+
+// ErrDiscarded is returned (wrapped) when reading from an Input positioned
+// before input that has already been discarded by Keep or Commit.
+var ErrDiscarded = errors.New("read of discarded input")
+
+// position is a location in the input. The line and col are counted from zero.
+// The cr flag records that the last byte was a carriage return, so that a
+// line feed following it is not counted as a second line break.
+type position struct {
+	offset, line, col int
+	cr                bool
 }
 
+// advance returns the position following the given bytes read starting at
+// this position. Columns are counted in runes, so a tab is one column. Each of
+// "\n", "\r\n", and "\r" end a line.
+func (ps position) advance(bs []byte) position {
+	for _, b := range bs {
+		ps.offset++
+		switch {
+		case b == '\n' && ps.cr:
+			// second half of "\r\n"
+		case b == '\n' || b == '\r':
+			ps.line++
+			ps.col = 0
+		case !utf8.RuneStart(b):
+			// continuation of a multibyte rune
+		default:
+			ps.col++
+		}
+		ps.cr = b == '\r'
+	}
+	return ps
+}
+
+// Buffer holds the input read ahead by the Readers of a single parse. Input is
+// kept in the buffer until it is discarded by Collect.
+//
+// The lock only keeps the state of the Buffer consistent. It does not make a
+// parse safe to run from several goroutines: a Collect made while another
+// Reader is reading changes what that Reader may read and invalidates any slice
+// returned by PeekRef. Each parse, meaning a root Input and all of its
+// descendants, must be driven from a single goroutine. Separate parses, each
+// with its own Buffer, may run in parallel.
 type Buffer struct {
-	r       *bufio.Reader
-	lock    sync.Mutex
-	offsets []int
+	r      *bufio.Reader
+	lock   sync.Mutex
+	gen    int
+	window int
+	pos    position
+	fail   furthest
+	memo   *Memo
+
+	// marks counts the outstanding marks, which keep the input from low on
+	// from being discarded by Keep or Commit
+	marks int
+	low   int
+}
+
+// furthest records the furthest offset at which a labeled matcher failed and
+// the labels of those that failed there.
+type furthest struct {
+	failed   bool
+	offset   int
+	expected []string
+}
+
+// record notes that the labeled matcher failed at the given offset.
+func (f *furthest) record(offset int, label string) {
+	switch {
+	case !f.failed || offset > f.offset:
+		f.failed = true
+		f.offset = offset
+		f.expected = []string{label}
+	case offset == f.offset:
+		for _, e := range f.expected {
+			if e == label {
+				return
+			}
+		}
+		f.expected = append(f.expected, label)
+	}
 }
 
 func NewBuffer(r io.Reader) *Buffer {
@@ -26,6 +109,47 @@ func NewBufferSize(r io.Reader, size int) *Buffer {
 	return &Buffer{r: bufio.NewReaderSize(r, size)}
 }
 
+// reset discards all buffered data and switches to reading from r. Readers
+// created before the reset fail with ErrDiscarded.
+func (b *Buffer) reset(r io.Reader) {
+	b.r.Reset(r)
+	b.gen++
+	b.pos = position{}
+	b.fail = furthest{}
+	b.memo = nil
+	b.marks = 0
+}
+
+// position returns the position found n bytes after the start of the buffer.
+func (b *Buffer) position(n int) position {
+	pbs, _ := b.r.Peek(n)
+	return b.pos.advance(pbs)
+}
+
+// rel converts an offset counted from the start of the input to an offset
+// counted from the start of the buffer. It returns an error wrapping
+// ErrDiscarded if the input at that offset has been discarded.
+func (b *Buffer) rel(off int) (int, error) {
+	if off < b.pos.offset {
+		return 0, fmt.Errorf("%w: byte %d is before byte %d",
+			ErrDiscarded, off, b.pos.offset)
+	}
+	return off - b.pos.offset, nil
+}
+
+// checkWindow returns an error wrapping ErrPeekWindow if reading up to the
+// given offset would go beyond the peek window.
+func (b *Buffer) checkWindow(end int) error {
+	if b.window > 0 && end > b.window {
+		return fmt.Errorf("%w: byte %d is beyond the window of %d bytes",
+			ErrPeekWindow, end, b.window)
+	}
+	return nil
+}
+
+// peek copies the bytes starting at the given offset into p. If fewer than
+// len(p) bytes are available, the bytes available are copied and their count
+// is returned with an error explaining why, such as io.EOF.
 func (b *Buffer) peek(
 	off int,
 	p []byte,
@@ -34,111 +158,228 @@ func (b *Buffer) peek(
 		return 0, nil
 	}
 
-	pbs, err := b.r.Peek(off + len(p))
+	pbs, err := b.peekRef(off, len(p))
+	return copy(p, pbs), err
+}
+
+// PeekRef returns the n bytes starting at the given offset, counted from the
+// start of the input, without copying them. The returned slice refers to the
+// internal buffer, so it is only valid until the next read or discard on this
+// Buffer and must not be modified. If fewer than n bytes are available, the
+// bytes available are returned with an error explaining why, such as io.EOF.
+func (b *Buffer) PeekRef(off, n int) ([]byte, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.peekRef(off, n)
+}
+
+// peekRef implements PeekRef without locking.
+func (b *Buffer) peekRef(off, n int) ([]byte, error) {
+	off, err := b.rel(off)
+	if err != nil {
+		return nil, err
+	}
+
+	// peek up to the window, but report the bytes that fit in it
+	werr := b.checkWindow(off + n)
+	if werr != nil {
+		n = b.window - off
+	}
+
+	pbs, err := b.r.Peek(off + n)
+	if werr != nil && err == nil {
+		err = werr
+	}
+
+	if len(pbs) <= off {
+		return nil, err
+	}
+
+	return pbs[off:], err
+}
+
+// peekByte returns the byte at the given offset.
+func (b *Buffer) peekByte(off int) (byte, error) {
+	off, err := b.rel(off)
 	if err != nil {
 		return 0, err
 	}
 
-	copy(p, pbs[off:])
+	if err := b.checkWindow(off + 1); err != nil {
+		return 0, err
+	}
 
-	return len(pbs[off:]), nil
+	pbs, err := b.r.Peek(off + 1)
+	if len(pbs) <= off {
+		return 0, err
+	}
+
+	return pbs[off], nil
 }
 
 func (b *Buffer) discard(n int) {
 	_, _ = b.r.Discard(n)
 }
 
+// peekRunes decodes runes into p starting at the given byte offset. It returns
+// the number of bytes decoded. If the input ends before p is filled, the bytes
+// decoded so far are returned with io.EOF, or ErrIncompleteRune if the input
+// ends part way through a rune.
 func (b *Buffer) peekRunes(off int, p []rune) (int, error) {
 	if len(p) == 0 {
 		return 0, nil
 	}
 
-	pbs, err := b.r.Peek(off + len(p))
-	if err != nil && !(len(pbs) > 0 && errors.Is(err, io.EOF)) {
+	off, err := b.rel(off)
+	if err != nil {
 		return 0, err
 	}
 
-	atEof := false
-	if errors.Is(err, io.EOF) {
-		atEof = true
-	}
-
-	pbs = pbs[off:]
-	glued := make([]byte, 0, len(p)*utf8.UTFMax)
 	total := 0
-	var readErr error
-	for i := 0; i < len(p); i++ {
-		var n int
-		readErr = nil
-		for {
-			switch {
-			case rune(pbs[0]) < utf8.RuneSelf:
-				// single byte rune, add it to the output and move on
-				p[i] = rune(pbs[0])
-				pbs = pbs[1:]
-				total += 1
-				break
-
-			case utf8.FullRune(pbs):
-				// complete multi-byte rune, add it to the output and move on
-				p[i], n = utf8.DecodeRune(pbs)
-				pbs = pbs[n:]
-				total += n
-				break
-
-			case atEof:
-				// EOF reached, decode the partial and quit
-				p[i], n = utf8.DecodeRune(pbs)
-				total += n
-				return total, nil
-
-			default:
-				// if we get here and we already had a read error last time,
-				// let's just croak here and now. This ensures that we quit.
-				if readErr != nil {
-					return 0, readErr
-				}
-
-				// we don't have a full rune, but there's more input: read more
-				glued = glued[:0]
-				glued = append(glued, pbs...)
-				pbs, err = b.r.Peek(off + total + len(p) - i)
-				if err != nil && !(len(pbs) > 0 && errors.Is(err, io.EOF)) {
-					return 0, err
-				}
-
-				atEof = errors.Is(err, io.EOF)
-
-				glued = append(glued, pbs[off+total:]...)
-				pbs = glued
+	for i := range p {
+		if err := b.checkWindow(off + total + 1); err != nil {
+			return total, err
+		}
+
+		// make sure we have enough bytes for a complete rune, but without
+		// peeking past the window
+		want := off + total + utf8.UTFMax
+		if b.window > 0 && want > b.window {
+			want = b.window
+		}
+
+		pbs, err := b.r.Peek(want)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return total, err
+		}
+
+		if len(pbs) <= off+total {
+			return total, io.EOF
+		}
+
+		if !utf8.FullRune(pbs[off+total:]) {
+			// a rune cut short by the end of input is incomplete
+			if len(pbs) < want {
+				return total, fmt.Errorf("%w: at byte %d",
+					ErrIncompleteRune, b.pos.offset+off+total)
 			}
+
+			// a rune cut short by the window is beyond the window
+			return total, b.checkWindow(off + total + utf8.UTFMax)
 		}
+
+		var n int
+		p[i], n = utf8.DecodeRune(pbs[off+total:])
+		total += n
 	}
 
 	return total, nil
 }
 
+// Reader reads from a Buffer starting at its own offset, so that several
+// Readers may read the same input. Reading does not discard input from the
+// Buffer. That is done by Collect.
 type Reader struct {
 	buf *Buffer
+	gen int
 	n   int
 }
 
 func (b *Buffer) Reader() *Reader {
-	return &Reader{b, 0}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return &Reader{b, b.gen, b.pos.offset}
 }
 
+// Collect discards the input before the position of the given Reader. Any
+// Reader positioned before it will fail with ErrDiscarded on the next read.
+// Nothing is discarded if the Reader is positioned before input that has
+// already been discarded.
 func (b *Buffer) Collect(r *Reader) {
-	b.discard(r.n)
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.collect(r, r.n)
+}
+
+// collectUnmarked works like Collect, except that no input is discarded from
+// the earliest outstanding mark on.
+func (b *Buffer) collectUnmarked(r *Reader) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	end := r.n
+	if b.marks > 0 && b.low < end {
+		end = b.low
+	}
+	b.collect(r, end)
+}
+
+// collect discards the input before end if r is current.
+func (b *Buffer) collect(r *Reader, end int) {
+	n := end - b.pos.offset
+	if r.gen != b.gen || n <= 0 {
+		return
+	}
+
+	b.pos = b.position(n)
+	b.discard(n)
+}
+
+// mark notes that the input from off on may be read again and must not be
+// discarded by Keep or Commit until unmark is called.
+func (b *Buffer) mark(off int) {
+	if b.marks == 0 || off < b.low {
+		b.low = off
+	}
+	b.marks++
+}
+
+// unmark releases a mark made with mark.
+func (b *Buffer) unmark() {
+	if b.marks > 0 {
+		b.marks--
+	}
 }
 
 func (r *Reader) Clone() *Reader {
-	return &Reader{r.buf, r.n}
+	return &Reader{r.buf, r.gen, r.n}
+}
+
+// check returns an error wrapping ErrDiscarded if the Buffer has been reset
+// since this Reader was created.
+func (r *Reader) check() error {
+	if r.gen != r.buf.gen {
+		return fmt.Errorf("%w: the input has been reset", ErrDiscarded)
+	}
+	return nil
+}
+
+// peekRef works like Buffer.PeekRef starting at the offset of this Reader.
+func (r *Reader) peekRef(n int) ([]byte, error) {
+	r.buf.lock.Lock()
+	defer r.buf.lock.Unlock()
+
+	if err := r.check(); err != nil {
+		return nil, err
+	}
+
+	return r.buf.peekRef(r.n, n)
 }
 
+// Read reads up to len(p) bytes into p. It follows the io.Reader convention: if
+// fewer than len(p) bytes remain, it reads them and returns their count along
+// with the error that ended the read, such as io.EOF.
 func (r *Reader) Read(p []byte) (n int, err error) {
 	r.buf.lock.Lock()
 	defer r.buf.lock.Unlock()
 
+	if err := r.check(); err != nil {
+		return 0, err
+	}
+
 	n, err = r.buf.peek(r.n, p)
 	r.n += n
 	if err != nil {
@@ -152,6 +393,10 @@ func (r *Reader) ReadRunes(p []rune) (n int, err error) {
 	r.buf.lock.Lock()
 	defer r.buf.lock.Unlock()
 
+	if err := r.check(); err != nil {
+		return 0, err
+	}
+
 	n, err = r.buf.peekRunes(r.n, p)
 	r.n += n
 	if err != nil {
@@ -161,6 +406,51 @@ func (r *Reader) ReadRunes(p []rune) (n int, err error) {
 	return n, nil
 }
 
+// ReadByte reads the next byte. It implements io.ByteReader.
+func (r *Reader) ReadByte() (byte, error) {
+	r.buf.lock.Lock()
+	defer r.buf.lock.Unlock()
+
+	if err := r.check(); err != nil {
+		return 0, err
+	}
+
+	c, err := r.buf.peekByte(r.n)
+	if err != nil {
+		return 0, err
+	}
+
+	r.n++
+	return c, nil
+}
+
+// ReadRune reads the next UTF-8 encoded rune and returns it along with its
+// size in bytes. It implements io.RuneReader.
+func (r *Reader) ReadRune() (rune, int, error) {
+	r.buf.lock.Lock()
+	defer r.buf.lock.Unlock()
+
+	if err := r.check(); err != nil {
+		return 0, 0, err
+	}
+
+	var rs [1]rune
+	n, err := r.buf.peekRunes(r.n, rs[:])
+	if n == 0 {
+		return 0, 0, err
+	}
+
+	r.n += n
+	return rs[0], n, nil
+}
+
+// Reset moves the Reader back to the start of the input that has not been
+// discarded. A Reader created before the Buffer was reset is usable again
+// after Reset.
 func (r *Reader) Reset() {
-	r.n = 0
+	r.buf.lock.Lock()
+	defer r.buf.lock.Unlock()
+
+	r.gen = r.buf.gen
+	r.n = r.buf.pos.offset
 }
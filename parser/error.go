@@ -0,0 +1,53 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseError describes where and why the input failed to parse.
+type ParseError struct {
+	Offset   int      // the byte offset, counting from zero
+	Line     int      // the line, counting from one
+	Column   int      // the column in runes, counting from one
+	Message  string   // describes the problem, such as "expected digit"
+	Expected []string // descriptions of what would have matched
+}
+
+// NewParseError returns a ParseError at the current Position of the given
+// Input, expecting any of the given descriptions. The Message is built from
+// the descriptions.
+func NewParseError(p *Input, expected ...string) *ParseError {
+	offset, line, col := p.Position()
+	return &ParseError{
+		Offset:   offset,
+		Line:     line,
+		Column:   col,
+		Message:  expectedMessage(expected),
+		Expected: expected,
+	}
+}
+
+// expectedMessage describes the expected descriptions, such as "expected
+// digit" or "expected one of digit, letter".
+func expectedMessage(expected []string) string {
+	switch len(expected) {
+	case 0:
+		return "parse error"
+	case 1:
+		return "expected " + expected[0]
+	default:
+		return "expected one of " + strings.Join(expected, ", ")
+	}
+}
+
+// Error returns the Message with the position, such as "expected digit at line
+// 3 col 12". If the Message is empty, one is built from Expected.
+func (e *ParseError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = expectedMessage(e.Expected)
+	}
+
+	return fmt.Sprintf("%s at line %d col %d", msg, e.Line, e.Column)
+}
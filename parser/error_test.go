@@ -0,0 +1,39 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zostay/gordy/parser"
+)
+
+func TestParseError_Error(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		expected []string
+		want     string
+	}{
+		{nil, "parse error at line 3 col 12"},
+		{[]string{"digit"}, "expected digit at line 3 col 12"},
+		{[]string{"digit", "letter"}, "expected one of digit, letter at line 3 col 12"},
+	}
+
+	for _, tt := range tests {
+		err := &parser.ParseError{Offset: 40, Line: 3, Column: 12, Expected: tt.expected}
+		assert.Equal(t, tt.want, err.Error())
+	}
+}
+
+func TestParseError_Message(t *testing.T) {
+	t.Parallel()
+
+	err := &parser.ParseError{
+		Line:     2,
+		Column:   5,
+		Message:  "unterminated string",
+		Expected: []string{`"\""`},
+	}
+	assert.Equal(t, "unterminated string at line 2 col 5", err.Error())
+}
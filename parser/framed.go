@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// ErrNotFramed is returned by NextFrame when called on an Input that was not
+// created with NewFramed.
+var ErrNotFramed = errors.New("input is not framed")
+
+// framer holds the source of frames for an Input created with NewFramed.
+type framer struct {
+	src       io.Reader
+	readFrame func(io.Reader) ([]byte, error)
+}
+
+// NewFramed creates a new parser for recursive descent parsing of a stream
+// made up of frames, such as the messages of a message-oriented protocol. The
+// readFrame function is called to read each frame from r. Each frame is parsed
+// in isolation: the Input reports io.EOF at the end of the frame, so no matcher
+// can read across a frame boundary.
+//
+// The returned Input starts out empty. Call NextFrame to load each frame,
+// starting with the first.
+func NewFramed(
+	r io.Reader,
+	readFrame func(io.Reader) ([]byte, error),
+) *Input {
+	p := New(bytes.NewReader(nil))
+	p.frames = &framer{src: r, readFrame: readFrame}
+	return p
+}
+
+// NextFrame discards whatever remains of the current frame and loads the next
+// frame into the Input. State set with SetValue is cleared as well. It returns
+// io.EOF when there are no more frames or any error returned while reading the
+// frame. Any Input created from this one with MayFail before calling
+// NextFrame must not be used afterward: reading from one returns an error
+// wrapping ErrDiscarded.
+func (p *Input) NextFrame() error {
+	root := p
+	for root.parent != nil {
+		root = root.parent
+	}
+
+	if root.frames == nil {
+		return ErrNotFramed
+	}
+
+	frame, err := root.frames.readFrame(root.frames.src)
+	if err != nil {
+		return err
+	}
+
+	root.buf.reset(bytes.NewReader(frame))
+	root.r.Reset()
+	root.values = nil
+	return nil
+}
@@ -0,0 +1,93 @@
+package parser_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/gordy/match"
+	"github.com/zostay/gordy/parser"
+	"github.com/zostay/gordy/token"
+)
+
+// readLengthPrefixed reads a frame made of a single length byte followed by
+// that many bytes of payload.
+func readLengthPrefixed(r io.Reader) ([]byte, error) {
+	var l [1]byte
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return nil, err
+	}
+
+	frame := make([]byte, l[0])
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+
+	return frame, nil
+}
+
+func TestNewFramed(t *testing.T) {
+	t.Parallel()
+
+	stream := bytes.NewReader([]byte("\x03123\x0245\x00"))
+	p := parser.NewFramed(stream, readLengthPrefixed)
+
+	digits := match.Seq(token.Literal,
+		match.NBytes(token.Literal, 1, 10, match.BytesInRange('0', '9')),
+		match.EOF(),
+	)
+
+	var got []string
+	for {
+		err := p.NextFrame()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		m, err := digits.Match(p)
+		require.NoError(t, err)
+		if m == nil {
+			got = append(got, "<nil>")
+			continue
+		}
+
+		got = append(got, string(m.Content))
+	}
+
+	assert.Equal(t, []string{"123", "45", "<nil>"}, got)
+}
+
+func TestNewFramed_Leftover(t *testing.T) {
+	t.Parallel()
+
+	stream := bytes.NewReader([]byte("\x03abc\x02de"))
+	p := parser.NewFramed(stream, readLengthPrefixed)
+
+	a := match.OneByte(token.Literal, match.BytesInSet('a'))
+
+	require.NoError(t, p.NextFrame())
+	m, err := a.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+
+	// the rest of the first frame is dropped
+	require.NoError(t, p.NextFrame())
+	var bs [2]byte
+	n, err := p.Read(bs[:])
+	assert.NoError(t, err)
+	assert.Equal(t, "de", string(bs[:n]))
+
+	assert.ErrorIs(t, p.NextFrame(), io.EOF)
+}
+
+func TestInput_NextFrame_NotFramed(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(bytes.NewReader([]byte("abc")))
+	assert.ErrorIs(t, p.NextFrame(), parser.ErrNotFramed)
+}
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
@@ -21,14 +22,43 @@ const (
 	StageFail
 )
 
+// TraceLevel selects which stages Trace passes to the TraceFunc.
+type TraceLevel int
+
+const (
+	TraceAll  TraceLevel = iota // trace every stage, the default
+	TraceFail                   // trace only StageFail
+	TraceOff                    // trace nothing
+)
+
+// String returns the name of the constant for the level, such as "TraceFail".
+// It returns a string like "TraceLevel(7)" for any other value.
+func (l TraceLevel) String() string {
+	switch l {
+	case TraceAll:
+		return "TraceAll"
+	case TraceFail:
+		return "TraceFail"
+	case TraceOff:
+		return "TraceOff"
+	}
+	return fmt.Sprintf("TraceLevel(%d)", int(l))
+}
+
 // Input provides the tool for keeping track of how the parser input is being
-// read during the parsing process.
+// read during the parsing process. An Input and all the Inputs created from it
+// with MayFail share one Buffer and must be used from a single goroutine.
 type Input struct {
-	TraceFunc Tracer
+	TraceFunc  Tracer
+	TraceLevel TraceLevel
 
 	parent *Input
 	buf    *Buffer
-	r      *Reader
+	r      Reader
+	scoped bool
+	values map[any]any
+	shared bool
+	frames *framer
 }
 
 // New creates a new parser for recursive descent parsing using the
@@ -37,7 +67,7 @@ func New(r io.Reader) *Input {
 	buf := NewBuffer(r)
 	return &Input{
 		buf: buf,
-		r:   buf.Reader(),
+		r:   *buf.Reader(),
 	}
 }
 
@@ -46,14 +76,139 @@ func New(r io.Reader) *Input {
 func NewSize(r io.Reader, size int) *Input {
 	buf := NewBufferSize(r, size)
 	return &Input{
-		buf: NewBufferSize(r, size),
-		r:   buf.Reader(),
+		buf: buf,
+		r:   *buf.Reader(),
+	}
+}
+
+// Reset discards all input and state and starts over reading from r, reusing
+// the buffer already allocated. The buffer size, peek window, TraceFunc, and
+// TraceLevel are kept. This allows one Input to parse many small inputs, such
+// as the messages read by a server. Reset may be called on any Input, but
+// always resets the root Input. An Input created with NewFramed becomes an
+// ordinary Input reading from r. Any Input created from it with MayFail before
+// the call must not be used afterward: reading from one returns an error
+// wrapping ErrDiscarded.
+func (p *Input) Reset(r io.Reader) {
+	root := p
+	for root.parent != nil {
+		root = root.parent
+	}
+
+	root.buf.lock.Lock()
+	root.buf.reset(r)
+	root.buf.lock.Unlock()
+
+	root.r.Reset()
+	root.values = nil
+	root.frames = nil
+}
+
+// Position returns the location in the input this Input will read from next.
+// The offset is the number of bytes read from the start of the input, counting
+// from zero. The line and col are counted from one and col is counted in
+// runes, so a tab counts as one column. A line ends with "\n", "\r\n", or
+// "\r". Only reads that have been kept move the position of an Input, so a
+// discarded child never moves its parent.
+//
+// If the input at this position has already been discarded by Keep or Commit,
+// the line and col are unknown and returned as 0.
+func (p *Input) Position() (offset, line, col int) {
+	p.buf.lock.Lock()
+	defer p.buf.lock.Unlock()
+
+	n := p.r.n - p.buf.pos.offset
+	if n < 0 {
+		return p.r.n, 0, 0
+	}
+
+	pos := p.buf.position(n)
+	return pos.offset, pos.line + 1, pos.col + 1
+}
+
+// Offset returns the byte offset this Input will read from next, counting from
+// the start of the input. It is the same as the offset returned by Position,
+// but cheaper to find.
+//
+// Take the offset before calling Keep. Once the parent has been updated, the
+// child no longer reports a useful offset.
+func (p *Input) Offset() int {
+	p.buf.lock.Lock()
+	defer p.buf.lock.Unlock()
+
+	return p.r.n
+}
+
+// RecordFailure notes that the matcher described by label failed to match at
+// the current offset of this Input. Only the failures at the furthest offset
+// are remembered. Unlike values set with SetValue, failures are remembered
+// even when this Input is discarded, as the failures in alternatives that were
+// given up on are usually the best explanation of what went wrong.
+func (p *Input) RecordFailure(label string) {
+	p.buf.lock.Lock()
+	defer p.buf.lock.Unlock()
+
+	p.buf.fail.record(p.r.n, label)
+}
+
+// FurthestFailure returns the furthest offset at which a failure was recorded
+// with RecordFailure and the labels of every matcher that failed there, in the
+// order they failed. It returns -1 and nil if no failure has been recorded.
+// After a parse fails to match, this can be used to report something like
+// "expected one of digit, letter at offset 12".
+func (p *Input) FurthestFailure() (offset int, expected []string) {
+	p.buf.lock.Lock()
+	defer p.buf.lock.Unlock()
+
+	if !p.buf.fail.failed {
+		return -1, nil
 	}
+
+	expected = make([]string, len(p.buf.fail.expected))
+	copy(expected, p.buf.fail.expected)
+	return p.buf.fail.offset, expected
+}
+
+// Buffered returns the number of bytes of input currently held in the buffer,
+// whether they have been read yet or not. Input is read into a buffer of fixed
+// size, set by NewSize, which is never grown, so this is never more than the
+// buffer size. Input is released from the buffer as it is kept (see Commit).
+func (p *Input) Buffered() int {
+	p.buf.lock.Lock()
+	defer p.buf.lock.Unlock()
+
+	return p.buf.r.Buffered()
+}
+
+// SetPeekWindow limits how far ahead of the kept input any read may look to n
+// bytes. Input is kept by calling Keep on the root Input or one of its direct
+// descendants, or by calling Commit. A read that would go beyond the window
+// returns an error wrapping ErrPeekWindow instead of buffering more input, so a
+// matcher looking for something that never appears fails rather than holding
+// on to ever more input. A window of 0 removes the limit, leaving only the
+// limit of the buffer size. The window is shared by the root Input and all of
+// its descendants.
+func (p *Input) SetPeekWindow(n int) {
+	p.buf.lock.Lock()
+	defer p.buf.lock.Unlock()
+
+	p.buf.window = n
 }
 
 // Trace may be called to help track the progress through a parse for help in
-// debugging.
+// debugging. It passes a description of the stage to TraceFunc, if set, unless
+// the stage is filtered out by TraceLevel. Inputs created with MayFail have the
+// same TraceFunc and TraceLevel as their parent.
 func (p *Input) Trace(stage Stage, name string, args ...any) {
+	switch p.TraceLevel {
+	case TraceOff:
+		return
+	case TraceFail:
+		if stage != StageFail {
+			return
+		}
+	}
+
 	if p.TraceFunc != nil {
 		out := &strings.Builder{}
 		switch stage {
@@ -73,36 +228,42 @@ func (p *Input) Trace(stage Stage, name string, args ...any) {
 		fmt.Fprint(out, string(bs[:n]))
 		fmt.Fprint(out, "…")
 
+		closed := false
 		for i, arg := range args {
-			fmt.Fprint(out, ", ")
-
-			if reflect.TypeOf(arg).Kind() == reflect.Func {
-				fmt.Fprint(out, runtime.FuncForPC(reflect.ValueOf(arg).Pointer()).Name())
-				continue
-			}
-
 			if i == len(args)-1 {
 				if err, isErr := arg.(error); isErr {
 					fmt.Fprintf(out, "): %v", err)
-					return
+					closed = true
+					break
 				}
 
 				if m, isMatch := arg.(*Match); isMatch {
 					fmt.Fprintf(out, ") = %v", m)
-					return
+					closed = true
+					break
 				}
 			}
 
+			fmt.Fprint(out, ", ")
+
+			if arg != nil && reflect.TypeOf(arg).Kind() == reflect.Func {
+				fmt.Fprint(out, runtime.FuncForPC(reflect.ValueOf(arg).Pointer()).Name())
+				continue
+			}
+
 			fmt.Fprint(out, arg)
 		}
 
-		fmt.Fprint(out, ")")
+		if !closed {
+			fmt.Fprint(out, ")")
+		}
 
 		p.TraceFunc(out.String())
 	}
 }
 
-// Read reads the next bytes from input.
+// Read reads the next bytes from input. If fewer than len(bs) bytes remain, it
+// reads those that do and returns their count with io.EOF.
 func (p *Input) Read(bs []byte) (int, error) {
 	return p.r.Read(bs)
 }
@@ -112,15 +273,124 @@ func (p *Input) ReadRunes(rs []rune) (int, error) {
 	return p.r.ReadRunes(rs)
 }
 
+// ReadByte reads the next byte from input. It implements io.ByteReader.
+func (p *Input) ReadByte() (byte, error) {
+	return p.r.ReadByte()
+}
+
+// ReadRune reads the next rune from input and returns it with its size in
+// bytes. It implements io.RuneReader.
+func (p *Input) ReadRune() (rune, int, error) {
+	return p.r.ReadRune()
+}
+
+// PeekRef returns the next n bytes of input without reading them. The returned
+// slice refers to the internal buffer, so it is only valid until the next read
+// from this Input or any other Input sharing its buffer and it must not be
+// modified. Copy the bytes or use Read if they are needed longer. If fewer than
+// n bytes are available, the bytes available are returned with an error
+// explaining why, such as io.EOF.
+func (p *Input) PeekRef(n int) ([]byte, error) {
+	return p.r.peekRef(n)
+}
+
+// AtEOF reports whether all of the input has been read, without reading any.
+// It reports false if any byte remains, even one that cannot be read because
+// of the peek window, and false if the input cannot be read at all, as after
+// it has been discarded.
+func (p *Input) AtEOF() bool {
+	bs, err := p.r.peekRef(1)
+	return len(bs) == 0 && errors.Is(err, io.EOF)
+}
+
 // MayFail returns a new Input that can be used to read input starting at the
 // offset of the current Input. Reads on the returned Input will not impact
 // the parent. When finished, you may call Keep on the child parser if you are
-// ready to keep the reads made.
+// ready to keep the reads made. Try does this for you.
+//
+// Several children of the same Input may be read from side by side, as when
+// trying alternatives. If one of them is kept and input is discarded as a
+// result, the others go on reading from their own positions. However, one that
+// is positioned before the discarded input returns an error wrapping
+// ErrDiscarded when read from.
 func (p *Input) MayFail() *Input {
 	return &Input{
-		parent: p,
-		buf:    p.buf,
-		r:      p.r.Clone(),
+		TraceFunc:  p.TraceFunc,
+		TraceLevel: p.TraceLevel,
+		parent:     p,
+		buf:        p.buf,
+		r:          p.r,
+	}
+}
+
+// Try runs fn against a child of this Input created with MayFail. If fn
+// returns a Match, the child is kept, so this Input moves past the input fn
+// read. If fn returns no Match or an error, the child is discarded, leaving this
+// Input as it was. It returns what fn returns.
+func (p *Input) Try(fn func(*Input) (*Match, error)) (*Match, error) {
+	c := p.MayFail()
+
+	m, err := fn(c)
+	if err != nil || m == nil {
+		c.Discard()
+		return nil, err
+	}
+
+	c.Keep()
+	return m, nil
+}
+
+// Scope returns a new Input that reads starting at the offset of the current
+// Input, just like MayFail. However, the returned Input starts with empty state:
+// values set on the parent are not visible through Value and values set on the
+// scoped Input are not passed back to the parent on Keep.
+func (p *Input) Scope() *Input {
+	c := p.MayFail()
+	c.scoped = true
+	return c
+}
+
+// Value returns the state value stored with the given key by SetValue on this
+// Input or any of its ancestors. It returns nil if no value is stored for the
+// key.
+func (p *Input) Value(key any) any {
+	for q := p; q != nil; q = q.parent {
+		if v, ok := q.values[key]; ok {
+			return v
+		}
+
+		if q.scoped {
+			break
+		}
+	}
+	return nil
+}
+
+// SetValue stores a state value with the given key. State is kept in step with
+// the input: values set on a child Input are passed to the parent on Keep and
+// are thrown away when the child is discarded. Matchers should treat values as
+// immutable and set a new value rather than modifying a value in place, or the
+// change will not be undone if the input is discarded.
+func (p *Input) SetValue(key, value any) {
+	if p.values == nil || p.shared {
+		values := make(map[any]any, len(p.values)+1)
+		for k, v := range p.values {
+			values[k] = v
+		}
+		p.values = values
+		p.shared = false
+	}
+	p.values[key] = value
+}
+
+// keepValues copies the state values of this Input to the given Input.
+func (p *Input) keepValues(to *Input) {
+	if p.scoped || to == p {
+		return
+	}
+
+	for k, v := range p.values {
+		to.SetValue(k, v)
 	}
 }
 
@@ -129,7 +399,7 @@ func (p *Input) MayFail() *Input {
 //
 // When Keep is called on the root Input object or its direct descendants, it
 // will also free up memory by discarding data that won't be read again at the
-// start of the buffer.
+// start of the buffer. See Commit for more on how input is buffered.
 func (p *Input) Keep() *Input {
 	// detect root or child of root cases
 	var root *Input
@@ -141,13 +411,15 @@ func (p *Input) Keep() *Input {
 
 	// when we are at or child of root, we can discard the read bytes
 	if root != nil {
-		root.buf.Collect(p.r)
-		root.r.Reset()
+		p.keepValues(root)
+		root.buf.collectUnmarked(&p.r)
+		root.r = p.r
 		return root
 	}
 
 	// otherwise, we just want to make sure the parent moves forward to the
 	// cursor position in the input so far
+	p.keepValues(p.parent)
 	p.parent.r = p.r
 	return p.parent
 }
@@ -160,3 +432,85 @@ func (p *Input) Discard() *Input {
 	}
 	return p
 }
+
+// Mark is a position in the input saved by Input.Mark.
+type Mark struct {
+	gen    int
+	n      int
+	values map[any]any
+}
+
+// Mark saves the position and state values of this Input so that they can be
+// restored with Rewind. Unlike MayFail, it allocates nothing, so it is the
+// cheaper way to try Matchers that may fail against this same Input.
+//
+// Until the Mark is passed to Release, the input after it is not discarded by
+// Keep or Commit. Every Mark must be released exactly once when no longer
+// needed.
+func (p *Input) Mark() Mark {
+	p.buf.lock.Lock()
+	defer p.buf.lock.Unlock()
+
+	p.buf.mark(p.r.n)
+	p.shared = p.values != nil
+	return Mark{gen: p.r.gen, n: p.r.n, values: p.values}
+}
+
+// Rewind moves this Input back to the given Mark, which must have been returned
+// by Mark on this same Input, and restores the state values saved with it. An
+// Input may be rewound to the same Mark any number of times until it is
+// released.
+//
+// If the input at the Mark has been discarded, as by Reset, the Input is left
+// as it is and an error wrapping ErrDiscarded is returned.
+func (p *Input) Rewind(m Mark) error {
+	p.buf.lock.Lock()
+	defer p.buf.lock.Unlock()
+
+	if m.gen != p.buf.gen {
+		return fmt.Errorf("%w: the input has been reset", ErrDiscarded)
+	}
+
+	if _, err := p.buf.rel(m.n); err != nil {
+		return err
+	}
+
+	p.r.n = m.n
+	p.values = m.values
+	p.shared = p.values != nil
+	return nil
+}
+
+// Release lets the input after the given Mark be discarded again. It does not
+// move the Input.
+func (p *Input) Release(m Mark) {
+	p.buf.lock.Lock()
+	defer p.buf.lock.Unlock()
+
+	if m.gen == p.buf.gen {
+		p.buf.unmark()
+	}
+}
+
+// Commit discards all the buffered input before the position of this Input. Use
+// it when a parse has reached a point it will never backtrack from, such as
+// the end of each record in a long list of records.
+//
+// All reads are made from a buffer of fixed size, set by NewSize. Input is only
+// discarded from the buffer when Keep is called on the root Input or one of its
+// direct descendants, so an Input nested more deeply than that may read ahead
+// at most the buffer size before reads fail with bufio.ErrBufferFull. Commit
+// lets a deeply nested Input discard the input it has read, so that a parse of
+// any length needs no more memory than the buffer size.
+//
+// Commit does not discard the input from any outstanding Mark on, just as Keep
+// does not, since a matcher holding a Mark may still backtrack to it. So a
+// Commit made inside an alternative of a matcher such as First, TryAndKeep, or
+// Longest discards nothing the other alternatives may still read.
+//
+// After Commit, any Input positioned before the discarded input, such as the
+// parent of this Input, returns an error wrapping ErrDiscarded if it is read
+// from. Call Keep on this Input and its ancestors to move them forward instead.
+func (p *Input) Commit() {
+	p.buf.collectUnmarked(&p.r)
+}
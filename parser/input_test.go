@@ -0,0 +1,892 @@
+package parser_test
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/gordy/match"
+	"github.com/zostay/gordy/parser"
+	"github.com/zostay/gordy/token"
+)
+
+func TestInput_Trace(t *testing.T) {
+	t.Parallel()
+
+	var lines []string
+	p := parser.New(strings.NewReader("12a"))
+	p.TraceFunc = func(v ...any) {
+		require.Len(t, v, 1)
+		lines = append(lines, v[0].(string))
+	}
+
+	digit := match.OneByte(token.Literal, match.BytesInRange('0', '9'))
+	m, err := match.Many(token.Literal, 1, digit).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+
+	require.NotEmpty(t, lines)
+	assert.True(t, strings.HasPrefix(lines[0], "TRY Bytes.Match("), lines[0])
+
+	var gotBytes, gotMany bool
+	for _, line := range lines {
+		gotBytes = gotBytes || strings.HasPrefix(line, "GOT Bytes.Match(")
+		gotMany = gotMany || strings.HasPrefix(line, "GOT MatchMany(")
+	}
+	assert.True(t, gotBytes, "traced GOT Bytes.Match")
+	assert.True(t, gotMany, "traced GOT MatchMany")
+}
+
+func TestInput_TraceFormat(t *testing.T) {
+	t.Parallel()
+
+	var lines []string
+	p := parser.New(strings.NewReader("0123456789abc"))
+	p.TraceFunc = func(v ...any) {
+		lines = append(lines, v[0].(string))
+	}
+
+	p.Trace(parser.StageTry, "Thing", 1, nil)
+	p.Trace(parser.StageFail, "Thing", 1, assert.AnError)
+
+	assert.Equal(t, []string{
+		"TRY Thing(0123456789…, 1, <nil>)",
+		"ERR Thing(0123456789…, 1): " + assert.AnError.Error(),
+	}, lines)
+}
+
+func TestInput_TraceLevel(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		level parser.TraceLevel
+		want  string
+	}{
+		{parser.TraceAll, "TRY Thing(abc…)\nGOT Other(abc…)\nERR Thing(abc…): " +
+			assert.AnError.Error() + "\n"},
+		{parser.TraceFail, "ERR Thing(abc…): " + assert.AnError.Error() + "\n"},
+		{parser.TraceOff, ""},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.level.String(), func(t *testing.T) {
+			t.Parallel()
+
+			var out strings.Builder
+			p := parser.New(strings.NewReader("abc"))
+			p.TraceFunc = func(v ...any) { fmt.Fprintln(&out, v...) }
+			p.TraceLevel = tt.level
+
+			// children trace the same way
+			c := p.MayFail()
+			c.Trace(parser.StageTry, "Thing")
+			c.Trace(parser.StageGot, "Other")
+			c.Trace(parser.StageFail, "Thing", assert.AnError)
+
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func TestTraceLevel_String(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "TraceAll", parser.TraceAll.String())
+	assert.Equal(t, "TraceFail", parser.TraceFail.String())
+	assert.Equal(t, "TraceOff", parser.TraceOff.String())
+	assert.Equal(t, "TraceLevel(7)", parser.TraceLevel(7).String())
+}
+
+func TestInput_SetPeekWindow(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("abcdefgh"))
+	p.SetPeekWindow(4)
+
+	c := p.MayFail()
+	var bs [4]byte
+	n, err := c.Read(bs[:])
+	assert.NoError(t, err)
+	assert.Equal(t, "abcd", string(bs[:n]))
+
+	// reading past the window fails without reading more
+	var b [1]byte
+	_, err = c.Read(b[:])
+	assert.ErrorIs(t, err, parser.ErrPeekWindow)
+
+	_, err = c.ReadRunes(make([]rune, 1))
+	assert.ErrorIs(t, err, parser.ErrPeekWindow)
+
+	// once kept, the window moves forward
+	c.Keep()
+	n, err = p.Read(bs[:])
+	assert.NoError(t, err)
+	assert.Equal(t, "efgh", string(bs[:n]))
+
+	// no limit
+	p = parser.New(strings.NewReader("abcdefgh"))
+	p.SetPeekWindow(4)
+	p.SetPeekWindow(0)
+
+	var all [8]byte
+	n, err = p.MayFail().Read(all[:])
+	assert.NoError(t, err)
+	assert.Equal(t, "abcdefgh", string(all[:n]))
+}
+
+func TestInput_SetPeekWindowRunes(t *testing.T) {
+	t.Parallel()
+
+	// each rune is 2 bytes, so the second only partly fits in a 3 byte window
+	p := parser.New(strings.NewReader("αβγ"))
+	p.SetPeekWindow(3)
+
+	rs := make([]rune, 2)
+	n, err := p.MayFail().ReadRunes(rs)
+	assert.ErrorIs(t, err, parser.ErrPeekWindow)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 'α', rs[0])
+}
+
+func TestInput_Position(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("ab\ncdé\n\nfg"))
+
+	read := func(in *parser.Input, n int) {
+		bs := make([]byte, n)
+		_, err := in.Read(bs)
+		require.NoError(t, err)
+	}
+
+	assertPos := func(in *parser.Input, offset, line, col int) {
+		t.Helper()
+		o, l, c := in.Position()
+		assert.Equal(t, offset, o, "offset")
+		assert.Equal(t, line, l, "line")
+		assert.Equal(t, col, c, "col")
+	}
+
+	assertPos(p, 0, 1, 1)
+
+	c := p.MayFail()
+	read(c, 2)
+	assertPos(c, 2, 1, 3)
+	assertPos(p, 0, 1, 1)
+
+	p = c.Keep()
+	assertPos(p, 2, 1, 3)
+
+	// a discarded child does not move the parent
+	c = p.MayFail()
+	read(c, 4)
+	assertPos(c, 6, 2, 4)
+	p = c.Discard()
+	assertPos(p, 2, 1, 3)
+
+	// é is two bytes, but one column
+	c = p.MayFail()
+	read(c, 1)
+	gc := c.MayFail()
+	read(gc, 4)
+	assertPos(gc, 7, 2, 4)
+	c = gc.Keep()
+	assertPos(c, 7, 2, 4)
+	p = c.Keep()
+	assertPos(p, 7, 2, 4)
+
+	c = p.MayFail()
+	read(c, 3)
+	p = c.Keep()
+	assertPos(p, 10, 4, 2)
+}
+
+func TestNewSize(t *testing.T) {
+	t.Parallel()
+
+	var in strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&in, "line %d\n", i)
+	}
+	require.Greater(t, in.Len(), 4096)
+
+	line := match.TryAndKeep(match.Seq(token.Literal,
+		match.NBytes(token.Literal, 1, 20, match.NotBytes(match.BytesInSet('\n'))),
+		match.OneByte(token.Literal, match.BytesInSet('\n')),
+	))
+
+	p := parser.NewSize(strings.NewReader(in.String()), 32)
+
+	var out strings.Builder
+	for {
+		m, err := line.Match(p)
+		require.NoError(t, err)
+		if m == nil {
+			break
+		}
+		out.Write(m.Content)
+	}
+
+	assert.Equal(t, in.String(), out.String())
+}
+
+func TestInput_PositionLineEndings(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		input     string
+		line, col int
+	}{
+		{"lf", "a\nb\nc", 3, 2},
+		{"crlf", "a\r\nb\r\nc", 3, 2},
+		{"cr", "a\rb\rc", 3, 2},
+		{"blank crlf lines", "a\r\n\r\nc", 3, 2},
+		{"tab", "\tx\ty", 1, 5},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader(tt.input))
+
+			// keep one byte at a time so each byte is counted separately
+			for i := 0; i < len(tt.input); i++ {
+				c := p.MayFail()
+				var b [1]byte
+				_, err := c.Read(b[:])
+				require.NoError(t, err)
+				p = c.Keep()
+			}
+
+			offset, line, col := p.Position()
+			assert.Equal(t, len(tt.input), offset)
+			assert.Equal(t, tt.line, line)
+			assert.Equal(t, tt.col, col)
+		})
+	}
+}
+
+func TestInput_PositionSplitCRLF(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("a\r\nb"))
+
+	c := p.MayFail()
+	var bs [2]byte
+	_, err := c.Read(bs[:])
+	require.NoError(t, err)
+	p = c.Keep()
+
+	_, line, col := p.Position()
+	assert.Equal(t, 2, line)
+	assert.Equal(t, 1, col)
+
+	// the \n completes the line break already counted
+	c = p.MayFail()
+	_, err = c.Read(bs[:1])
+	require.NoError(t, err)
+
+	_, line, col = c.Position()
+	assert.Equal(t, 2, line)
+	assert.Equal(t, 1, col)
+}
+
+func TestInput_ReadByteRune(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("aé"))
+
+	var _ io.ByteReader = p
+	var _ io.RuneReader = p
+
+	c, err := p.ReadByte()
+	assert.NoError(t, err)
+	assert.Equal(t, byte('a'), c)
+
+	r, n, err := p.ReadRune()
+	assert.NoError(t, err)
+	assert.Equal(t, 'é', r)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 3, p.Offset())
+
+	_, err = p.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+
+	_, n, err = p.ReadRune()
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 3, p.Offset())
+
+	// the peek window applies too
+	p = parser.New(strings.NewReader("ab"))
+	p.SetPeekWindow(1)
+	_, err = p.ReadByte()
+	assert.NoError(t, err)
+	_, err = p.ReadByte()
+	assert.ErrorIs(t, err, parser.ErrPeekWindow)
+}
+
+func TestInput_Commit(t *testing.T) {
+	t.Parallel()
+
+	// far more input than the tiny buffer holds
+	const records = 10000
+	in := strings.Repeat("record\n", records)
+
+	line := match.TryAndKeep(match.Seq(token.Literal,
+		match.TakeWhile1(token.Literal, match.BytesInRange('a', 'z')),
+		match.OneByte(token.Literal, match.BytesInSet('\n')),
+	))
+
+	committed := parser.MatcherFunc(func(p *parser.Input) (*parser.Match, error) {
+		m, err := line.Match(p)
+		if m != nil {
+			p.Commit()
+		}
+		return m, err
+	})
+
+	// nested so that no Keep is close enough to the root to discard input
+	nested := func(mtch parser.Matcher) parser.Matcher {
+		many := match.Many(token.Literal, 1, mtch)
+		return parser.MatcherFunc(func(p *parser.Input) (*parser.Match, error) {
+			c := p.MayFail().MayFail()
+			m, err := many.Match(c)
+			if m != nil {
+				c.Keep().Keep()
+			}
+			return m, err
+		})
+	}
+
+	// without Commit, the buffer fills up
+	p := parser.NewSize(strings.NewReader(in), 16)
+	m, err := nested(line).Match(p)
+	assert.ErrorIs(t, err, bufio.ErrBufferFull)
+	assert.Nil(t, m)
+
+	// with Commit, the whole input is parsed within the buffer
+	p = parser.NewSize(strings.NewReader(in), 16)
+	m, err = nested(committed).Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Len(t, m.Submatch, records)
+	assert.Equal(t, len(in), m.End)
+
+	offset, line1, col := p.Position()
+	assert.Equal(t, len(in), offset)
+	assert.Equal(t, records+1, line1)
+	assert.Equal(t, 1, col)
+}
+
+func TestInput_CommitDiscards(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("abcdef"))
+	c := p.MayFail().MayFail()
+
+	var bs [3]byte
+	_, err := c.Read(bs[:])
+	assert.NoError(t, err)
+	c.Commit()
+
+	// the parent is still positioned before the discarded input
+	assert.Equal(t, 0, p.Offset())
+	_, line, col := p.Position()
+	assert.Equal(t, 0, line)
+	assert.Equal(t, 0, col)
+	_, err = p.Read(bs[:])
+	assert.ErrorIs(t, err, parser.ErrDiscarded)
+
+	// the child goes on reading where it left off
+	_, err = c.Read(bs[:])
+	assert.NoError(t, err)
+	assert.Equal(t, "def", string(bs[:]))
+
+	// and keeping it moves the parent forward again
+	c.Keep().Keep()
+	assert.Equal(t, 6, p.Offset())
+}
+
+func TestInput_KeepWithSiblings(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("abcdef"))
+	ahead := p.MayFail()
+	behind := p.MayFail()
+	kept := p.MayFail()
+
+	var bs [3]byte
+	_, err := ahead.Read(bs[:3])
+	assert.NoError(t, err)
+	_, err = behind.Read(bs[:1])
+	assert.NoError(t, err)
+	_, err = kept.Read(bs[:2])
+	assert.NoError(t, err)
+
+	// keeping a child of the root discards "ab"
+	kept.Keep()
+	assert.Equal(t, 2, p.Offset())
+
+	// a sibling further ahead reads on from where it was
+	assert.Equal(t, 3, ahead.Offset())
+	n, err := ahead.Read(bs[:])
+	assert.NoError(t, err)
+	assert.Equal(t, "def", string(bs[:n]))
+
+	// a sibling behind is refused rather than reading the wrong bytes
+	assert.Equal(t, 1, behind.Offset())
+	_, err = behind.Read(bs[:1])
+	assert.ErrorIs(t, err, parser.ErrDiscarded)
+	_, err = behind.ReadByte()
+	assert.ErrorIs(t, err, parser.ErrDiscarded)
+	_, _, err = behind.ReadRune()
+	assert.ErrorIs(t, err, parser.ErrDiscarded)
+
+	// the root reads on from what was kept
+	n, err = p.Read(bs[:])
+	assert.NoError(t, err)
+	assert.Equal(t, "cde", string(bs[:n]))
+}
+
+func TestInput_PeekRef(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("abcdef"))
+	var bs [2]byte
+	_, err := p.Read(bs[:])
+	assert.NoError(t, err)
+
+	ref, err := p.PeekRef(3)
+	assert.NoError(t, err)
+
+	// the same bytes as a copying read, but nothing is consumed
+	cp := make([]byte, 3)
+	_, err = p.MayFail().Read(cp)
+	assert.NoError(t, err)
+	assert.Equal(t, cp, ref)
+	assert.Equal(t, 2, p.Offset())
+
+	// fewer bytes at the end of input
+	ref, err = p.PeekRef(10)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, "cdef", string(ref))
+
+	// the peek window applies
+	p.SetPeekWindow(4)
+	ref, err = p.PeekRef(3)
+	assert.ErrorIs(t, err, parser.ErrPeekWindow)
+	assert.Equal(t, "cd", string(ref))
+}
+
+func TestBuffer_PeekRef(t *testing.T) {
+	t.Parallel()
+
+	buf := parser.NewBuffer(strings.NewReader("abcdef"))
+	r := buf.Reader()
+
+	cp := make([]byte, 4)
+	_, err := r.Read(cp)
+	assert.NoError(t, err)
+
+	ref, err := buf.PeekRef(1, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, cp[1:], ref)
+
+	// once collected, the input before the reader is gone
+	buf.Collect(r)
+	_, err = buf.PeekRef(1, 3)
+	assert.ErrorIs(t, err, parser.ErrDiscarded)
+
+	ref, err = buf.PeekRef(4, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "ef", string(ref))
+}
+
+// repeatReader returns the same text n times without holding it all in memory.
+type repeatReader struct {
+	text string
+	n    int
+	off  int
+}
+
+func (r *repeatReader) Read(bs []byte) (int, error) {
+	total := 0
+	for len(bs) > 0 && r.n > 0 {
+		c := copy(bs, r.text[r.off:])
+		bs = bs[c:]
+		total += c
+		r.off += c
+		if r.off == len(r.text) {
+			r.off = 0
+			r.n--
+		}
+	}
+
+	if total == 0 {
+		return 0, io.EOF
+	}
+	return total, nil
+}
+
+func TestInput_StreamsThroughSmallBuffer(t *testing.T) {
+	t.Parallel()
+
+	// about 600 KB of input, far more than the 512 byte buffer
+	const words = 100000
+	const size = 512
+	input := func() io.Reader { return &repeatReader{text: "token ", n: words} }
+
+	word := match.Lexeme(
+		match.TakeWhile1(token.Literal, match.BytesInRange('a', 'z')),
+		match.OptWhitespace(),
+	)
+
+	// read every word from p, committing after each if asked
+	readAll := func(p *parser.Input, commit bool) (int, error) {
+		count := 0
+		for {
+			m, err := word.Match(p)
+			if err != nil || m == nil {
+				return count, err
+			}
+
+			count++
+			if p.Offset() != count*len("token ") {
+				return count, fmt.Errorf("offset %d after %d words", p.Offset(), count)
+			}
+
+			if commit {
+				p.Commit()
+			}
+		}
+	}
+
+	// at the root, each Keep discards the input read
+	p := parser.NewSize(input(), size)
+	count, err := readAll(p, false)
+	assert.NoError(t, err)
+	assert.Equal(t, words, count)
+
+	// nested deeper, nothing is discarded and the buffer fills
+	p = parser.NewSize(input(), size)
+	count, err = readAll(p.MayFail().MayFail(), false)
+	assert.ErrorIs(t, err, bufio.ErrBufferFull)
+	assert.Less(t, count, size/len("token ")+1)
+
+	// unless the nested parse commits as it goes
+	p = parser.NewSize(input(), size)
+	c := p.MayFail().MayFail()
+	count, err = readAll(c, true)
+	assert.NoError(t, err)
+	assert.Equal(t, words, count)
+	assert.Equal(t, words*len("token "), c.Offset())
+}
+
+func TestInput_Reset(t *testing.T) {
+	t.Parallel()
+
+	number := match.TakeWhile1(token.Literal, match.BytesInRange('0', '9'))
+	key := &struct{}{}
+
+	p := parser.New(strings.NewReader("123\n456"))
+	p.SetValue(key, "first")
+	p.RecordFailure("digit")
+
+	m, err := number.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "123", m.Text())
+
+	stale := p.MayFail()
+
+	// the rest of the first input is thrown away
+	p.Reset(strings.NewReader("78 9"))
+	assert.Equal(t, 0, p.Offset())
+	offset, line, col := p.Position()
+	assert.Equal(t, 0, offset)
+	assert.Equal(t, 1, line)
+	assert.Equal(t, 1, col)
+	assert.Nil(t, p.Value(key))
+	offset, expected := p.FurthestFailure()
+	assert.Equal(t, -1, offset)
+	assert.Nil(t, expected)
+
+	m, err = number.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "78", m.Text())
+	assert.Equal(t, 0, m.Start)
+	assert.Equal(t, 2, p.Offset())
+
+	// an Input from before the reset refuses to read
+	var bs [1]byte
+	_, err = stale.Read(bs[:])
+	assert.ErrorIs(t, err, parser.ErrDiscarded)
+	_, err = stale.PeekRef(1)
+	assert.ErrorIs(t, err, parser.ErrDiscarded)
+
+	// resetting from a child resets the root
+	c := p.MayFail()
+	c.Reset(strings.NewReader("5"))
+	m, err = number.Match(p)
+	assert.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "5", m.Text())
+}
+
+func TestInput_ReadRunesIncomplete(t *testing.T) {
+	t.Parallel()
+
+	// "a" then the first two bytes of the three byte "€"
+	truncated := "a" + "€"[:2]
+
+	p := parser.New(strings.NewReader(truncated))
+	var rs [2]rune
+	n, err := p.MayFail().ReadRunes(rs[:])
+	assert.ErrorIs(t, err, parser.ErrIncompleteRune)
+	assert.EqualError(t, err, "incomplete rune at end of input: at byte 1")
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 'a', rs[0])
+
+	r, _, err := p.ReadRune()
+	assert.NoError(t, err)
+	assert.Equal(t, 'a', r)
+
+	_, size, err := p.ReadRune()
+	assert.ErrorIs(t, err, parser.ErrIncompleteRune)
+	assert.Equal(t, 0, size)
+
+	// an invalid byte that is not cut short is still a replacement character
+	p = parser.New(strings.NewReader("\xffb"))
+	n, err = p.ReadRunes(rs[:])
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []rune{utf8.RuneError, 'b'}, rs[:])
+}
+
+func TestInput_ReadPartial(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("abcdef"))
+
+	bs := make([]byte, 4)
+	n, err := p.Read(bs)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, "abcd", string(bs[:n]))
+
+	// the final read returns what remains along with EOF
+	n, err = p.Read(bs)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "ef", string(bs[:n]))
+	assert.Equal(t, 6, p.Offset())
+
+	n, err = p.Read(bs)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 6, p.Offset())
+
+	// so the Input works with io.ReadAll
+	p = parser.New(strings.NewReader("abcdef"))
+	c := p.MayFail()
+	all, err := io.ReadAll(c)
+	assert.NoError(t, err)
+	assert.Equal(t, "abcdef", string(all))
+
+	// a read stopped by the peek window returns the bytes inside it
+	p = parser.New(strings.NewReader("abcdef"))
+	p.SetPeekWindow(3)
+	n, err = p.Read(bs)
+	assert.ErrorIs(t, err, parser.ErrPeekWindow)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, "abc", string(bs[:n]))
+}
+
+func TestInput_AtEOF(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("ab"))
+	assert.False(t, p.AtEOF())
+
+	_, err := p.ReadByte()
+	assert.NoError(t, err)
+	assert.False(t, p.AtEOF())
+	assert.Equal(t, 1, p.Offset())
+
+	_, err = p.ReadByte()
+	assert.NoError(t, err)
+	assert.True(t, p.AtEOF())
+	assert.Equal(t, 2, p.Offset())
+
+	// reading to the end in a child does not move the parent
+	p = parser.New(strings.NewReader("ab"))
+	c := p.MayFail()
+	_, err = c.Read(make([]byte, 2))
+	assert.NoError(t, err)
+	assert.True(t, c.AtEOF())
+	assert.False(t, p.AtEOF())
+
+	assert.True(t, parser.New(strings.NewReader("")).AtEOF())
+
+	// input beyond the peek window remains
+	p = parser.New(strings.NewReader("ab"))
+	p.SetPeekWindow(1)
+	_, err = p.ReadByte()
+	assert.NoError(t, err)
+	assert.False(t, p.AtEOF())
+}
+
+func TestInput_MarkRewind(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("abcdef"))
+	p.SetValue("k", 1)
+
+	mark := p.Mark()
+	_, err := p.Read(make([]byte, 2))
+	assert.NoError(t, err)
+	p.SetValue("k", 2)
+	p.SetValue("j", 3)
+
+	// Keep on a child of the root does not discard the marked input
+	c := p.MayFail()
+	_, err = c.Read(make([]byte, 2))
+	assert.NoError(t, err)
+	c.Keep()
+	assert.Equal(t, 4, p.Offset())
+
+	assert.NoError(t, p.Rewind(mark))
+	assert.Equal(t, 0, p.Offset())
+	assert.Equal(t, 1, p.Value("k"))
+	assert.Nil(t, p.Value("j"))
+
+	// rewinding again works too
+	b, err := p.ReadByte()
+	assert.NoError(t, err)
+	assert.Equal(t, byte('a'), b)
+	assert.NoError(t, p.Rewind(mark))
+	assert.Equal(t, 0, p.Offset())
+
+	// once released, Keep discards the input again
+	p.Release(mark)
+	c = p.MayFail()
+	_, err = c.Read(make([]byte, 2))
+	assert.NoError(t, err)
+	c.Keep()
+	assert.ErrorIs(t, p.Rewind(mark), parser.ErrDiscarded)
+	assert.Equal(t, 2, p.Offset())
+}
+
+func TestInput_MarkCommit(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("abcdef"))
+	mark := p.Mark()
+
+	_, err := p.Read(make([]byte, 2))
+	assert.NoError(t, err)
+
+	// Commit leaves the marked input alone
+	p.Commit()
+	assert.NoError(t, p.Rewind(mark))
+	assert.Equal(t, 0, p.Offset())
+
+	// until the Mark is released
+	_, err = p.Read(make([]byte, 2))
+	assert.NoError(t, err)
+	p.Release(mark)
+	p.Commit()
+	assert.ErrorIs(t, p.Rewind(mark), parser.ErrDiscarded)
+	assert.Equal(t, 2, p.Offset())
+
+	mark = p.Mark()
+	defer p.Release(mark)
+	p.Reset(strings.NewReader("xyz"))
+	assert.ErrorIs(t, p.Rewind(mark), parser.ErrDiscarded)
+}
+
+func TestInput_CommitInAlternative(t *testing.T) {
+	t.Parallel()
+
+	a := match.String(token.Literal, "a")
+	commit := parser.MatcherFunc(func(p *parser.Input) (*parser.Match, error) {
+		p.Commit()
+		return &parser.Match{Tag: token.None}, nil
+	})
+
+	alts := []parser.Matcher{
+		match.Seq(token.Literal, a, commit, match.String(token.Literal, "x")),
+		match.Seq(token.Literal, a, match.String(token.Literal, "b")),
+	}
+
+	for name, mtch := range map[string]parser.Matcher{
+		"First":   match.First(alts...),
+		"Longest": match.Longest(alts...),
+	} {
+		p := parser.New(strings.NewReader("ab"))
+		m, err := mtch.Match(p)
+		assert.NoError(t, err, name)
+		require.NotNil(t, m, name)
+		assert.Equal(t, "ab", m.Text(), name)
+		assert.Equal(t, 2, p.Offset(), name)
+	}
+}
+
+func TestInput_Try(t *testing.T) {
+	t.Parallel()
+
+	errBad := errors.New("bad")
+	read := func(n int, m *parser.Match, err error) func(*parser.Input) (*parser.Match, error) {
+		return func(p *parser.Input) (*parser.Match, error) {
+			if _, rerr := p.Read(make([]byte, n)); rerr != nil {
+				return nil, rerr
+			}
+			p.SetValue("read", n)
+			return m, err
+		}
+	}
+
+	tests := []struct {
+		name   string
+		fn     func(*parser.Input) (*parser.Match, error)
+		ok     bool
+		err    error
+		offset int
+	}{
+		{"success", read(2, &parser.Match{}, nil), true, nil, 2},
+		{"failure", read(2, nil, nil), false, nil, 0},
+		{"error", read(2, &parser.Match{}, errBad), false, errBad, 0},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser.New(strings.NewReader("abcd"))
+			m, err := p.Try(tt.fn)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.ok, m != nil)
+			assert.Equal(t, tt.offset, p.Offset())
+
+			if tt.ok {
+				assert.Equal(t, 2, p.Value("read"))
+			} else {
+				assert.Nil(t, p.Value("read"))
+			}
+		})
+	}
+}
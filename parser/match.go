@@ -1,6 +1,12 @@
 package parser
 
-import "github.com/zostay/gordy/token"
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zostay/gordy/token"
+)
 
 // Match is the object used to represent some segment of a parsed string.
 type Match struct {
@@ -9,6 +15,8 @@ type Match struct {
 	Group    map[string]*Match // identifies named submatches
 	Submatch []*Match          // identifies a list of submatches
 	Made     interface{}       // a place to put high-level objects generated from this match
+	Start    int               // the byte offset where the match starts
+	End      int               // the byte offset just after the match ends
 }
 
 // Length returns the number of bytes matched for this match.
@@ -20,6 +28,147 @@ func (m *Match) Length() int {
 	}
 }
 
+// String renders the match for debugging. The first line shows the tag (see
+// token.Tag.String) and the quoted content. Each submatch follows on its own
+// line, indented below its parent, prefixed with its group name when it has
+// one. A nil match is rendered as "<nil>".
+func (m *Match) String() string {
+	if m == nil {
+		return "<nil>"
+	}
+
+	out := &strings.Builder{}
+	m.render(out, "", "")
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// render writes the match tree to out with each line indented by the given
+// indent. The label is written before the match.
+func (m *Match) render(out *strings.Builder, indent, label string) {
+	fmt.Fprint(out, indent, label)
+	if m == nil {
+		fmt.Fprintln(out, "<nil>")
+		return
+	}
+
+	fmt.Fprintf(out, "%v %q\n", m.Tag, m.Content)
+
+	names := make(map[*Match]string, len(m.Group))
+	for name, gm := range m.Group {
+		names[gm] = name + ": "
+	}
+
+	for _, sm := range m.Submatch {
+		sm.render(out, indent+"  ", names[sm])
+	}
+}
+
+// Named returns the submatch in the Group with the given name and true. If
+// there is no such group, it returns nil and false. This distinguishes a group
+// that matched empty input from one that is not present.
+func (m *Match) Named(name string) (*Match, bool) {
+	if m == nil {
+		return nil, false
+	}
+
+	nm, ok := m.Group[name]
+	return nm, ok
+}
+
+// Get returns the submatch in the Group with the given name or nil if there is
+// no such group. Use Named to tell a missing group apart from a nil one.
+func (m *Match) Get(name string) *Match {
+	nm, _ := m.Named(name)
+	return nm
+}
+
+// Text returns the Content of the match as a string. It returns the empty
+// string for a nil match, so it may be combined with Get as in
+// m.Get("name").Text().
+func (m *Match) Text() string {
+	if m == nil {
+		return ""
+	}
+	return string(m.Content)
+}
+
+// Find returns the first match with the given tag, searching in the same order
+// as Walk. It returns nil if there is no such match.
+func (m *Match) Find(t token.Tag) *Match {
+	var found *Match
+	m.Walk(func(_ int, m *Match) bool {
+		if found != nil {
+			return false
+		}
+
+		if m.Tag == t {
+			found = m
+			return false
+		}
+
+		return true
+	})
+	return found
+}
+
+// FindAll returns every match with the given tag in the same order as Walk.
+func (m *Match) FindAll(t token.Tag) []*Match {
+	var found []*Match
+	m.Walk(func(_ int, m *Match) bool {
+		if m.Tag == t {
+			found = append(found, m)
+		}
+		return true
+	})
+	return found
+}
+
+// Walk calls fn for this match and each match below it, depth first, visiting
+// each match before its children. The depth is 0 for this match, 1 for its
+// submatches, and so on. The children of a match are its submatches, in
+// order, followed by the groups, by name, that are not also submatches. No
+// match is visited twice. When fn returns false, the children of that match
+// are skipped.
+func (m *Match) Walk(fn func(depth int, m *Match) bool) {
+	m.walk(0, map[*Match]bool{}, fn)
+}
+
+// walk implements Walk.
+func (m *Match) walk(depth int, seen map[*Match]bool, fn func(int, *Match) bool) {
+	if m == nil || seen[m] {
+		return
+	}
+	seen[m] = true
+
+	if !fn(depth, m) {
+		return
+	}
+
+	for _, sm := range m.Submatch {
+		sm.walk(depth+1, seen, fn)
+	}
+
+	names := make([]string, 0, len(m.Group))
+	for name := range m.Group {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		m.Group[name].walk(depth+1, seen, fn)
+	}
+}
+
+// Span returns the byte offsets of the start of the match and just after the
+// end of the match, counting from the start of the input. Use it to report
+// where a match was found.
+func (m *Match) Span() (start, end int) {
+	if m == nil {
+		return 0, 0
+	}
+	return m.Start, m.End
+}
+
 // BuildMatch is a short hand for building a match with named submatches.
 func BuildMatch(t token.Tag, ms ...any) (m *Match) {
 	g := make(map[string]*Match, len(ms)/2)
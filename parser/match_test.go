@@ -0,0 +1,158 @@
+package parser_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/gordy/match"
+	"github.com/zostay/gordy/parser"
+	"github.com/zostay/gordy/token"
+)
+
+func TestMatch_String(t *testing.T) {
+	t.Parallel()
+
+	lit := func(s string) *parser.Match {
+		return &parser.Match{Tag: token.Literal, Content: []byte(s)}
+	}
+
+	inner := &parser.Match{
+		Tag:      token.Last,
+		Content:  []byte(`x="1"`),
+		Submatch: []*parser.Match{lit("x"), lit("="), lit(`"1"`)},
+	}
+	m := parser.BuildMatch(token.Last+1,
+		"", lit("["),
+		"pair", inner,
+		"", lit("]"),
+	)
+	m.Submatch = append(m.Submatch, nil)
+
+	assert.Equal(t, `Tag(3) "[x=\"1\"]"
+  Literal "["
+  pair: Tag(2) "x=\"1\""
+    Literal "x"
+    Literal "="
+    Literal "\"1\""
+  Literal "]"
+  <nil>`, m.String())
+
+	assert.Equal(t, "<nil>", (*parser.Match)(nil).String())
+	assert.Equal(t, `Literal "a"`, fmt.Sprint(lit("a")))
+}
+
+func TestMatch_Named(t *testing.T) {
+	t.Parallel()
+
+	word := match.NBytes(token.Literal, 0, 10, match.BytesInRange('a', 'z'))
+	pair := match.SeqNamed(token.Literal,
+		"key", word,
+		"", match.OneByte(token.Literal, match.BytesInSet('=')),
+		"value", word,
+	)
+
+	p := parser.New(strings.NewReader("name="))
+	m, err := pair.Match(p)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+
+	key, ok := m.Named("key")
+	assert.True(t, ok)
+	assert.Equal(t, "name", string(key.Content))
+	assert.Equal(t, "name", m.Get("key").Text())
+
+	// matched, but empty
+	value, ok := m.Named("value")
+	assert.True(t, ok)
+	assert.NotNil(t, value)
+	assert.Equal(t, "", m.Get("value").Text())
+
+	missing, ok := m.Named("missing")
+	assert.False(t, ok)
+	assert.Nil(t, missing)
+	assert.Nil(t, m.Get("missing"))
+	assert.Equal(t, "", m.Get("missing").Text())
+
+	var none *parser.Match
+	_, ok = none.Named("key")
+	assert.False(t, ok)
+	assert.Nil(t, none.Get("key"))
+	assert.Equal(t, "", none.Get("key").Text())
+}
+
+func TestMatch_Walk(t *testing.T) {
+	t.Parallel()
+
+	const (
+		tA = token.Last + 10 + iota
+		tB
+		tC
+		tD
+		tE
+	)
+
+	leaf := func(t token.Tag) *parser.Match {
+		return &parser.Match{Tag: t}
+	}
+
+	d := leaf(tD)
+	e := leaf(tE)
+	b := &parser.Match{Tag: tB, Submatch: []*parser.Match{leaf(tC), d}}
+	root := &parser.Match{
+		Tag:      tA,
+		Submatch: []*parser.Match{b, nil},
+		// b is in both, e is only a group
+		Group: map[string]*parser.Match{"b": b, "e": e},
+	}
+
+	type visit struct {
+		depth int
+		tag   token.Tag
+	}
+
+	var visits []visit
+	root.Walk(func(depth int, m *parser.Match) bool {
+		visits = append(visits, visit{depth, m.Tag})
+		return true
+	})
+	assert.Equal(t, []visit{
+		{0, tA}, {1, tB}, {2, tC}, {2, tD}, {1, tE},
+	}, visits)
+
+	// prune below b
+	visits = nil
+	root.Walk(func(depth int, m *parser.Match) bool {
+		visits = append(visits, visit{depth, m.Tag})
+		return m.Tag != tB
+	})
+	assert.Equal(t, []visit{{0, tA}, {1, tB}, {1, tE}}, visits)
+
+	// no submatches at all
+	visits = nil
+	leaf(tC).Walk(func(depth int, m *parser.Match) bool {
+		visits = append(visits, visit{depth, m.Tag})
+		return true
+	})
+	assert.Equal(t, []visit{{0, tC}}, visits)
+
+	var none *parser.Match
+	none.Walk(func(int, *parser.Match) bool {
+		t.Fatal("visited a nil match")
+		return true
+	})
+}
+
+func TestMatch_Text(t *testing.T) {
+	t.Parallel()
+
+	m := &parser.Match{Tag: token.Literal, Content: []byte("abc")}
+	assert.Equal(t, "abc", m.Text())
+
+	var none *parser.Match
+	assert.Equal(t, "", none.Text())
+	assert.Equal(t, "", (&parser.Match{}).Text())
+}
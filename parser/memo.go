@@ -0,0 +1,59 @@
+package parser
+
+import "sync"
+
+// MemoEntry is the result of a Matcher remembered in a Memo.
+type MemoEntry struct {
+	Match *Match // the Match returned, which may be nil
+	Err   error  // the error returned
+	End   int    // the offset the Matcher had read to when it returned
+}
+
+// memoKey identifies a remembered result by Matcher and starting offset.
+type memoKey struct {
+	id     any
+	offset int
+}
+
+// Memo remembers the results of matchers by the offset where they started, so
+// that a matcher tried repeatedly at the same offset need only do the work
+// once. This is also known as packrat parsing. Offsets are counted from the
+// start of the input, so the entries stay correct as input is kept and
+// collected.
+type Memo struct {
+	lock    sync.Mutex
+	entries map[memoKey]MemoEntry
+}
+
+// Get returns the result remembered for the Matcher identified by id starting
+// at the given offset and true. It returns false if there is no such result.
+func (m *Memo) Get(id any, offset int) (MemoEntry, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	e, ok := m.entries[memoKey{id, offset}]
+	return e, ok
+}
+
+// Put remembers the result for the Matcher identified by id starting at the
+// given offset.
+func (m *Memo) Put(id any, offset int, e MemoEntry) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.entries == nil {
+		m.entries = make(map[memoKey]MemoEntry)
+	}
+	m.entries[memoKey{id, offset}] = e
+}
+
+// Memo returns the Memo shared by this Input, its ancestors, and descendants.
+func (p *Input) Memo() *Memo {
+	p.buf.lock.Lock()
+	defer p.buf.lock.Unlock()
+
+	if p.buf.memo == nil {
+		p.buf.memo = &Memo{}
+	}
+	return p.buf.memo
+}
@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// TokenStream pulls tokens one at a time from an Input, as a lexer does. Create
+// one with NewTokenStream.
+type TokenStream struct {
+	p    *Input
+	mtch Matcher
+}
+
+// NewTokenStream returns a TokenStream that reads tokens from p by matching
+// mtch again and again at the current position. The Matcher should match any
+// one token, usually by trying each kind of token in turn.
+func NewTokenStream(p *Input, mtch Matcher) *TokenStream {
+	return &TokenStream{p: p, mtch: mtch}
+}
+
+// Next matches the next token and returns its Match, moving the Input past it.
+// It returns io.EOF once all of the input has been read. If the Matcher fails
+// to match before then, or matches without reading any input, the stream is
+// stuck and Next returns a *ParseError at the position of the Input naming
+// what was expected there, as recorded by RecordFailure, or "token" if
+// nothing was recorded. Any error returned by the Matcher is returned as is.
+func (s *TokenStream) Next() (*Match, error) {
+	if s.p.AtEOF() {
+		return nil, io.EOF
+	}
+
+	c := s.p.MayFail()
+	m, err := s.mtch.Match(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if m == nil || c.Offset() == s.p.Offset() {
+		offset, expected := s.p.FurthestFailure()
+		if offset != s.p.Offset() || len(expected) == 0 {
+			expected = []string{"token"}
+		}
+		return nil, NewParseError(s.p, expected...)
+	}
+
+	c.Keep()
+	return m, nil
+}
+
+// StreamTokens reads tokens from p as a TokenStream does, but on a goroutine of
+// its own, sending each Match on the returned Match channel so that a lexer and
+// a parser may run side by side. The Match channel is closed after the last
+// token. If reading a token fails with any error but io.EOF, the error is sent
+// on the error channel first. Both channels are closed when the goroutine
+// ends, so the error channel yields nil once all the tokens are read.
+//
+// If ctx is done before all the tokens have been received, the goroutine sends
+// ctx.Err() on the error channel and ends, so a consumer that stops reading
+// should cancel ctx. The goroutine reads from p until it ends, so p must not
+// be used by anything else until the Match channel is closed.
+func StreamTokens(
+	ctx context.Context,
+	p *Input,
+	mtch Matcher,
+) (<-chan *Match, <-chan error) {
+	ms := make(chan *Match)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(ms)
+
+		s := NewTokenStream(p, mtch)
+		for {
+			if err := ctx.Err(); err != nil {
+				errs <- err
+				return
+			}
+
+			m, err := s.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			} else if err != nil {
+				errs <- err
+				return
+			}
+
+			select {
+			case ms <- m:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	return ms, errs
+}
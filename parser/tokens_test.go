@@ -0,0 +1,147 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zostay/gordy/match"
+	"github.com/zostay/gordy/parser"
+	"github.com/zostay/gordy/token"
+)
+
+var (
+	TNumber   = token.NextTag()
+	TOperator = token.NextTag()
+)
+
+var arithToken = match.First(
+	match.TakeWhile1(TNumber, match.BytesInRange('0', '9')),
+	match.Label("operator", match.OneByte(TOperator, match.BytesInSet('+', '-', '*', '/'))),
+)
+
+func TestTokenStream(t *testing.T) {
+	t.Parallel()
+
+	type tok struct {
+		tag  token.Tag
+		text string
+	}
+
+	s := parser.NewTokenStream(parser.New(strings.NewReader("1+2*3")), arithToken)
+
+	var got []tok
+	for {
+		m, err := s.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		got = append(got, tok{m.Tag, m.Text()})
+	}
+
+	assert.Equal(t, []tok{
+		{TNumber, "1"},
+		{TOperator, "+"},
+		{TNumber, "2"},
+		{TOperator, "*"},
+		{TNumber, "3"},
+	}, got)
+
+	// it stays at the end
+	_, err := s.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestTokenStream_Stuck(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("12x"))
+	s := parser.NewTokenStream(p, arithToken)
+
+	m, err := s.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "12", m.Text())
+
+	m, err = s.Next()
+	assert.Nil(t, m)
+
+	var perr *parser.ParseError
+	require.ErrorAs(t, err, &perr)
+	assert.Equal(t, 2, perr.Offset)
+	assert.Equal(t, []string{"operator"}, perr.Expected)
+	assert.Equal(t, 2, p.Offset())
+
+	// a token matching nothing would never finish
+	s = parser.NewTokenStream(parser.New(strings.NewReader("x")),
+		match.OptWhitespace())
+	_, err = s.Next()
+	require.ErrorAs(t, err, &perr)
+	assert.Equal(t, []string{"token"}, perr.Expected)
+}
+
+func TestStreamTokens(t *testing.T) {
+	t.Parallel()
+
+	p := parser.New(strings.NewReader("1+2*3"))
+	ms, errs := parser.StreamTokens(context.Background(), p, arithToken)
+
+	var got []string
+	for m := range ms {
+		got = append(got, m.Text())
+	}
+
+	assert.NoError(t, <-errs)
+	assert.Equal(t, []string{"1", "+", "2", "*", "3"}, got)
+
+	// a stuck stream sends the error after the tokens before it
+	p = parser.New(strings.NewReader("1+x"))
+	ms, errs = parser.StreamTokens(context.Background(), p, arithToken)
+
+	got = nil
+	for m := range ms {
+		got = append(got, m.Text())
+	}
+
+	var perr *parser.ParseError
+	assert.ErrorAs(t, <-errs, &perr)
+	assert.Equal(t, []string{"1", "+"}, got)
+}
+
+func TestStreamTokens_Cancel(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// far more tokens than will be read
+	p := parser.New(&repeatReader{text: "1+", n: 1000000})
+	ms, errs := parser.StreamTokens(ctx, p, arithToken)
+
+	for i := 0; i < 3; i++ {
+		m, ok := <-ms
+		require.True(t, ok)
+		assert.NotNil(t, m)
+	}
+
+	cancel()
+
+	// the goroutine ends, closing both channels, without the rest being read
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("goroutine did not end after cancel")
+	}
+
+	_, ok := <-errs
+	assert.False(t, ok)
+	_, ok = <-ms
+	assert.False(t, ok)
+}
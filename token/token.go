@@ -1,5 +1,10 @@
 package token
 
+import (
+	"fmt"
+	"sync"
+)
+
 // Tag is the abstract tag identifier used to tag matches by type in the
 // constructed abstract syntax tree.
 type Tag int
@@ -18,13 +23,48 @@ const (
 	Last
 )
 
-var prevTag = Last
+var (
+	lock    sync.RWMutex
+	prevTag = Last
+	names   = map[Tag]string{
+		None:    "None",
+		Literal: "Literal",
+	}
+)
 
 // NextTag provides an interface for assigning tags serial numbers at runtime to
 // avoid conflicts between tags when parsers from different modules are mixed
 // and matched. This returns the next available tag and should be called during
 // init.
 func NextTag() Tag {
+	lock.Lock()
+	defer lock.Unlock()
+
+	prevTag++
+	return prevTag
+}
+
+// Register works just like NextTag, but also records a name for the tag, which
+// is returned by its String method. Names need not be unique: registering the
+// same name twice returns two different tags with the same name. It is safe to
+// call from the init functions of several packages at once.
+func Register(name string) Tag {
+	lock.Lock()
+	defer lock.Unlock()
+
 	prevTag++
+	names[prevTag] = name
 	return prevTag
 }
+
+// String returns the name given to the tag by Register. It returns a string
+// like "Tag(7)" for a tag with no name.
+func (t Tag) String() string {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if name, ok := names[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("Tag(%d)", int(t))
+}
@@ -0,0 +1,100 @@
+package token_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zostay/gordy/token"
+)
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	tNumber := token.Register("Number")
+	tOperator := token.Register("Operator")
+	tPlain := token.NextTag()
+
+	assert.NotEqual(t, tNumber, tOperator)
+	assert.NotEqual(t, tOperator, tPlain)
+	assert.Greater(t, tNumber, token.Last)
+
+	assert.Equal(t, "Number", tNumber.String())
+	assert.Equal(t, "Operator", fmt.Sprint(tOperator))
+	assert.Equal(t, fmt.Sprintf("Tag(%d)", int(tPlain)), tPlain.String())
+
+	assert.Equal(t, "None", token.None.String())
+	assert.Equal(t, "Literal", token.Literal.String())
+	assert.Equal(t, "Tag(2)", token.Last.String())
+}
+
+func TestRegister_SameName(t *testing.T) {
+	t.Parallel()
+
+	// the same name from another grammar is a different tag
+	tIdent := token.Register("Ident")
+	tOther := token.Register("Ident")
+	assert.NotEqual(t, tIdent, tOther)
+	assert.Equal(t, "Ident", tIdent.String())
+	assert.Equal(t, "Ident", tOther.String())
+	assert.Equal(t, "Tag(1234567)", token.Tag(1234567).String())
+}
+
+func TestRegister_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	const workers, each = 8, 100
+
+	tags := make([][]token.Tag, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		w := w
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < each; i++ {
+				tags[w] = append(tags[w], token.Register(fmt.Sprintf("W%d.%d", w, i)))
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[token.Tag]bool, workers*each)
+	for w := range tags {
+		for i, tag := range tags[w] {
+			assert.False(t, seen[tag], "tag %d is unique", tag)
+			seen[tag] = true
+			assert.Equal(t, fmt.Sprintf("W%d.%d", w, i), tag.String())
+		}
+	}
+}
+
+func TestNextTag_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	const workers, each = 8, 1000
+
+	tags := make(chan token.Tag, workers*each)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < each; i++ {
+				tags <- token.NextTag()
+			}
+		}()
+	}
+	wg.Wait()
+	close(tags)
+
+	seen := make(map[token.Tag]bool, workers*each)
+	for tag := range tags {
+		assert.Greater(t, tag, token.Last)
+		assert.False(t, seen[tag], "tag %d is unique", tag)
+		seen[tag] = true
+	}
+	assert.Len(t, seen, workers*each)
+}